@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// numberFormat describes the separators used to write an amount
+type numberFormat struct {
+	// Digit grouping separator, empty for none
+	thousands string
+	// Separator between the whole and fractional parts
+	decimal string
+}
+
+var (
+	// Currency symbols stripped from amounts in the import file
+	currencySymbols = stringList{"£", "€", "$"}
+	// Separators of amounts in the import file
+	inputNumberFormat = numberFormat{thousands: ",", decimal: "."}
+	// Separators of amounts written to the output, which Xero only reads with a decimal point however
+	// the import file writes them
+	outputNumberFormat = numberFormat{decimal: "."}
+	// Amounts in the import file are whole numbers of minor units, this many to the unit, e.g. 100 for pence.
+	// Zero when amounts are written with a decimal separator.
+	minorUnits int64
+)
+
+// parseAmount converts an amount from the import file such as "-1,234.56" into a signed number of pence
+func parseAmount(value string) (int64, error) {
+	if minorUnits > 0 {
+		return parseMinorUnits(stripCurrencySymbols(value))
+	}
+	return parseAmountWith(stripCurrencySymbols(value), inputNumberFormat)
+}
+
+// parseMinorUnits converts a whole number of minor units such as "12345" into a signed number of pence.
+// Minor units finer than pence are rounded according to the rounding mode.
+func parseMinorUnits(value string) (int64, error) {
+	s, negative := amountSign(strings.TrimSpace(value))
+	if negative && (strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+")) {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	units, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("amount %q is not a whole number of minor units, see -minorunits", value)
+	}
+	if units > math.MaxInt64/100 || units < -math.MaxInt64/100 {
+		return 0, fmt.Errorf("amount %q is too large", value)
+	}
+	if negative {
+		units = -units
+	}
+	if units*100%minorUnits == 0 {
+		return units * 100 / minorUnits, nil
+	}
+	if roundingMode == roundNone {
+		return 0, fmt.Errorf("amount %q is not a whole number of pence, see -roundingmode", value)
+	}
+	return roundPence(float64(units) * 100 / float64(minorUnits)), nil
+}
+
+// stripCurrencySymbols removes any currency symbols from an amount, e.g. "£123.45"
+func stripCurrencySymbols(value string) string {
+	stripped := value
+	for _, symbol := range currencySymbols {
+		if symbol = strings.TrimSpace(symbol); symbol != "" {
+			stripped = strings.Replace(stripped, symbol, "", -1)
+		}
+	}
+	if stripped != value {
+		log.Debugf("Stripped the currency symbol from amount %q", value)
+	}
+	return stripped
+}
+
+// parseAmountWith converts an amount written with the given separators into a signed number of pence.
+// Besides a leading sign, debits may be written in parentheses, with a trailing minus as in "123.45-",
+// or with a CR or DR suffix.
+func parseAmountWith(value string, format numberFormat) (int64, error) {
+	s, negative := amountSign(strings.TrimSpace(value))
+	if isSpaceSeparator(format.thousands) {
+		s = stripSpaces(s)
+	} else if format.thousands != "" {
+		s = strings.Replace(s, format.thousands, "", -1)
+	}
+	if format.decimal != "." {
+		s = strings.Replace(s, format.decimal, ".", 1)
+	}
+	if s == "" {
+		return 0, fmt.Errorf("empty amount")
+	}
+
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		if negative || len(s) == 1 {
+			return 0, fmt.Errorf("invalid amount %q", value)
+		}
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+
+	whole, fraction := s, ""
+	if i := strings.Index(s, "."); i >= 0 {
+		whole, fraction = s[:i], s[i+1:]
+	}
+	// Digits beyond the pence, rounded away according to the rounding mode
+	var rest string
+	if len(fraction) > 2 {
+		if roundingMode == roundNone {
+			return 0, fmt.Errorf("amount %q has more than two decimal places, see -roundingmode", value)
+		}
+		fraction, rest = fraction[:2], fraction[2:]
+		if strings.Trim(rest, "0123456789") != "" {
+			return 0, fmt.Errorf("invalid amount %q", value)
+		}
+	}
+	fraction += strings.Repeat("0", 2-len(fraction))
+	if whole == "" {
+		whole = "0"
+	}
+
+	pounds, err := strconv.ParseUint(whole, 10, 63)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	pence, err := strconv.ParseUint(fraction, 10, 63)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+
+	if pounds > (math.MaxInt64-pence)/100 {
+		return 0, fmt.Errorf("amount %q is too large", value)
+	}
+	amount := int64(pounds*100 + pence)
+	if roundUp(rest, amount) {
+		if amount == math.MaxInt64 {
+			return 0, fmt.Errorf("amount %q is too large", value)
+		}
+		amount++
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// Spaces that group digits, e.g. "1 234,56", as exports use them interchangeably
+var groupingSpaces = []string{" ", "\u00a0", "\u202f"}
+
+// isSpaceSeparator reports whether a digit grouping separator is one of the grouping spaces
+func isSpaceSeparator(separator string) bool {
+	return containsString(groupingSpaces, separator)
+}
+
+// stripSpaces removes every grouping space from an amount
+func stripSpaces(s string) string {
+	for _, space := range groupingSpaces {
+		s = strings.Replace(s, space, "", -1)
+	}
+	return s
+}
+
+// Rounding modes for amounts with more than two decimal places
+const (
+	// Amounts with more than two decimal places are an error
+	roundNone = "none"
+	// Halves round away from zero
+	roundHalfUp = "halfup"
+	// Halves round to the even penny, so they don't skew totals
+	roundHalfEven = "halfeven"
+	// Extra digits are dropped, rounding towards zero
+	roundDown = "down"
+)
+
+// Rounding of amounts with more than two decimal places, also used for converted amounts.
+// Rounded amounts make totals, and running balance checks, differ from the source by up to a penny a row.
+var roundingMode = roundNone
+
+// validateRoundingMode checks the -roundingmode value
+func validateRoundingMode(mode string) error {
+	switch mode {
+	case roundNone, roundHalfUp, roundHalfEven, roundDown:
+		return nil
+	}
+	return fmt.Errorf("unknown rounding mode %q, expected %s, %s, %s or %s", mode, roundNone, roundHalfUp, roundHalfEven, roundDown)
+}
+
+// roundUp reports whether an unsigned amount in pence goes up a penny, given the digits after the pence
+func roundUp(rest string, pence int64) bool {
+	rest = strings.TrimRight(rest, "0")
+	if rest == "" || roundingMode == roundDown {
+		return false
+	}
+	switch {
+	case rest[0] > '5', rest[0] == '5' && len(rest) > 1:
+		return true
+	case rest[0] < '5':
+		return false
+	}
+	// Exactly half a penny
+	return roundingMode == roundHalfUp || pence%2 == 1
+}
+
+// roundPence rounds a fractional number of pence according to the rounding mode
+func roundPence(pence float64) int64 {
+	switch roundingMode {
+	case roundHalfEven:
+		return int64(math.RoundToEven(pence))
+	case roundDown:
+		return int64(math.Trunc(pence))
+	}
+	return int64(math.Round(pence))
+}
+
+// amountSign strips the debit and credit markers other than a leading sign from an amount,
+// reporting whether they mark it as negative
+func amountSign(s string) (string, bool) {
+	upper := strings.ToUpper(s)
+	switch {
+	case strings.HasSuffix(upper, "CR"):
+		return strings.TrimSpace(s[:len(s)-2]), false
+	case strings.HasSuffix(upper, "DR"):
+		return strings.TrimSpace(s[:len(s)-2]), true
+	case len(s) > 2 && strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")"):
+		return strings.TrimSpace(s[1 : len(s)-1]), true
+	case len(s) > 1 && strings.HasSuffix(s, "-"):
+		return strings.TrimSpace(s[:len(s)-1]), true
+	}
+	return s, false
+}
+
+// formatAmount converts a signed number of pence into an output amount
+func formatAmount(amount int64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d%s%02d", sign, amount/100, outputNumberFormat.decimal, amount%100)
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/baloo32/xerobanktransform/internal/statementgen"
+)
+
+func TestParseAmountWith(t *testing.T) {
+	tests := []struct {
+		value    string
+		format   numberFormat
+		rounding string
+		want     int64
+		wantErr  bool
+	}{
+		{"1,234.56", numberFormat{thousands: ",", decimal: "."}, roundNone, 123456, false},
+		{"1.234,56", numberFormat{thousands: ".", decimal: ","}, roundNone, 123456, false},
+		{"(25.99)", numberFormat{decimal: "."}, roundNone, -2599, false},
+		{"92233720368547758.07", numberFormat{decimal: "."}, roundNone, 9223372036854775807, false},
+		{"92233720368547758.08", numberFormat{decimal: "."}, roundNone, 0, true},
+		{"-92233720368547758.07", numberFormat{decimal: "."}, roundNone, -9223372036854775807, false},
+		{"92233720368547758.075", numberFormat{decimal: "."}, roundHalfUp, 0, true},
+		{"92233720368547758.075", numberFormat{decimal: "."}, roundDown, 9223372036854775807, false},
+		{"99999999999999999999", numberFormat{decimal: "."}, roundNone, 0, true},
+	}
+	for _, tt := range tests {
+		setForTest(t, &roundingMode, tt.rounding)
+		got, err := parseAmountWith(tt.value, tt.format)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q with %s rounding: got error %v, want error %v", tt.value, tt.rounding, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q with %s rounding: got %d pence, want %d", tt.value, tt.rounding, got, tt.want)
+		}
+	}
+}
+
+func TestParseMinorUnits(t *testing.T) {
+	tests := []struct {
+		value   string
+		units   int64
+		want    int64
+		wantErr bool
+	}{
+		{"12345", 100, 12345, false},
+		{"-12345", 100, -12345, false},
+		{"(12345)", 100, -12345, false},
+		{"12345", 1, 1234500, false},
+		{"92233720368547758", 1, 9223372036854775800, false},
+		{"-92233720368547758", 1, -9223372036854775800, false},
+		{"92233720368547759", 1, 0, true},
+		{"-92233720368547759", 1, 0, true},
+		{"-922337203685477580", 1, 0, true},
+		{"12.34", 100, 0, true},
+	}
+	for _, tt := range tests {
+		setForTest(t, &minorUnits, tt.units)
+		got, err := parseMinorUnits(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q in %d minor units: got error %v, want error %v", tt.value, tt.units, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q in %d minor units: got %d pence, want %d", tt.value, tt.units, got, tt.want)
+		}
+	}
+}
+
+func TestFormatAmountDecimalPoint(t *testing.T) {
+	setForTest(t, &inputNumberFormat, numberFormat{thousands: ".", decimal: ","})
+	if got := formatAmount(-115000); got != "-1150.00" {
+		t.Errorf("got %q, want -1150.00", got)
+	}
+}
+
+func FuzzParseAmount(f *testing.F) {
+	for _, amount := range statementgen.Amounts {
+		for _, mode := range []string{roundNone, roundHalfUp, roundHalfEven, roundDown} {
+			f.Add(amount, mode)
+		}
+	}
+	f.Add("92233720368547758.075", roundHalfUp)
+	f.Fuzz(func(t *testing.T, value, mode string) {
+		if validateRoundingMode(mode) != nil {
+			return
+		}
+		setForTest(t, &roundingMode, mode)
+		amount, err := parseAmount(value)
+		if err != nil {
+			return
+		}
+		// A parsed amount is written so that it reads back the same
+		formatted := formatAmount(amount)
+		again, err := parseAmountWith(formatted, outputNumberFormat)
+		if err != nil {
+			t.Fatalf("amount %q was written as %q, which doesn't parse: %s", value, formatted, err)
+		}
+		if again != amount {
+			t.Fatalf("amount %q was written as %q, which reads back as %d pence, not %d", value, formatted, again, amount)
+		}
+	})
+}
+
+func TestParseAmountSigns(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{"123.45-", -12345, false},
+		{"1,234.56-", -123456, false},
+		{"£1,234.56 -", -123456, false},
+		{"(1,234.56)", -123456, false},
+		{"1,234.56 DR", -123456, false},
+		{"1,234.56cr", 123456, false},
+		{"-1,234.56", -123456, false},
+		{"+1,234.56", 123456, false},
+		{"-123.45-", 0, true},
+		{"(123.45-)", 0, true},
+		{"-", 0, true},
+		{"--1", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseAmount(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: got error %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %d pence, want %d", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestTrailingMinusTransactionType(t *testing.T) {
+	statement := "Date,Amount\n01/06/2020,\"1,234.56-\"\n02/06/2020,123.45\n"
+	got := outputRows(transformStatement(t, statement, func(p *Preset) {
+		p.HeaderSignature = []string{"Date", "Amount"}
+		p.Columns = ColumnMapping{Date: "Date", Amount: "Amount"}
+	}))
+	want := []string{"01/06/2020,-1234.56,,,,,Debit", "02/06/2020,123.45,,,,,Credit"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got rows %q, want %q", got, want)
+	}
+}
+
+func TestRoundingModes(t *testing.T) {
+	tests := []struct {
+		value string
+		mode  string
+		want  int64
+	}{
+		{"1.005", roundHalfUp, 101},
+		{"1.005", roundHalfEven, 100},
+		{"1.005", roundDown, 100},
+		{"1.015", roundHalfUp, 102},
+		{"1.015", roundHalfEven, 102},
+		{"1.0050", roundHalfEven, 100},
+		{"1.0051", roundHalfEven, 101},
+		{"1.004", roundHalfUp, 100},
+		{"1.009", roundDown, 100},
+		{"-1.005", roundHalfUp, -101},
+		{"-1.005", roundHalfEven, -100},
+		{"-1.009", roundDown, -100},
+		{"(2.675)", roundHalfUp, -268},
+	}
+	for _, tt := range tests {
+		setForTest(t, &roundingMode, tt.mode)
+		got, err := parseAmount(tt.value)
+		if err != nil {
+			t.Errorf("%q with %s rounding: %s", tt.value, tt.mode, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q with %s rounding: got %d pence, want %d", tt.value, tt.mode, got, tt.want)
+		}
+	}
+
+	setForTest(t, &roundingMode, roundNone)
+	if _, err := parseAmount("1.005"); err == nil {
+		t.Errorf("1.005 with no rounding: got no error")
+	}
+}
+
+func TestRoundPence(t *testing.T) {
+	tests := []struct {
+		pence float64
+		mode  string
+		want  int64
+	}{
+		{100.5, roundHalfUp, 101},
+		{100.5, roundHalfEven, 100},
+		{101.5, roundHalfEven, 102},
+		{100.9, roundDown, 100},
+		{-100.5, roundHalfUp, -101},
+		{-100.5, roundHalfEven, -100},
+		{-100.9, roundDown, -100},
+	}
+	for _, tt := range tests {
+		setForTest(t, &roundingMode, tt.mode)
+		if got := roundPence(tt.pence); got != tt.want {
+			t.Errorf("%v with %s rounding: got %d, want %d", tt.pence, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestRoundedTotals(t *testing.T) {
+	setForTest(t, &roundingMode, roundHalfEven)
+	statement := statementHeader +
+		"01/06/2020,A,R1,X,,1.005,1\n" +
+		"02/06/2020,B,R2,X,,1.015,1\n" +
+		"03/06/2020,C,R3,X,2.025,,1\n"
+	got := outputRows(transformStatement(t, statement, nil))
+	want := []string{
+		"01/06/2020,1.00,,X,A R1,,Credit",
+		"02/06/2020,1.02,,X,B R2,,Credit",
+		"03/06/2020,-2.02,,X,C R3,,Debit",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got rows %q, want %q", got, want)
+	}
+	// The totals add up the rounded amounts written, not the amounts in the statement
+	if summary.Credits != 202 || summary.Debits != 202 {
+		t.Errorf("got credits %d and debits %d pence, want 202 and 202", summary.Credits, summary.Debits)
+	}
+}
+
+func TestSpaceThousandsSeparator(t *testing.T) {
+	tests := []struct {
+		value     string
+		thousands string
+		want      int64
+		wantErr   bool
+	}{
+		{"1 234,56", " ", 123456, false},
+		{"1\u00a0234,56", " ", 123456, false},
+		{"1\u202f234\u202f567,89", " ", 123456789, false},
+		{"-1\u00a0234,56", " ", -123456, false},
+		{"1\u00a0234,56", "\u00a0", 123456, false},
+		{"1 234,56", "\u00a0", 123456, false},
+		{"1\u00a0234,56", ".", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseAmountWith(tt.value, numberFormat{thousands: tt.thousands, decimal: ","})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q grouped by %q: got error %v, want error %v", tt.value, tt.thousands, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q grouped by %q: got %d pence, want %d", tt.value, tt.thousands, got, tt.want)
+		}
+	}
+}
+
+func TestSpaceGroupedStatement(t *testing.T) {
+	setForTest(t, &inputNumberFormat, numberFormat{thousands: " ", decimal: ","})
+	statement := statementHeader +
+		"01/06/2020,CARD,REF1,TESCO,\"1\u00a0234,56\",,1\n" +
+		"02/06/2020,PAY,REF2,INV,,\"12 000,00\",1\n"
+	got := outputRows(transformStatement(t, statement, nil))
+	want := []string{"01/06/2020,-1234.56,,TESCO,CARD REF1,,Debit", "02/06/2020,12000.00,,INV,PAY REF2,,Credit"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got rows %q, want %q", got, want)
+	}
+}
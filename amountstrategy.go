@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Strategies for reading the amount of a transaction, tried in the order a preset lists them
+const (
+	// Unsigned amounts in separate debit and credit columns
+	amountSeparate = "separate"
+	// A signed amount in the amount column
+	amountSigned = "signed"
+	// An amount in the amount column marked with a CR or DR suffix
+	amountSuffix = "suffix"
+	// An unsigned amount in the amount column, with the indicator column saying which way it goes
+	amountIndicator = "indicator"
+	// An unsigned amount in the amount column, with the direction column saying whether it's a debit
+	amountDirection = "direction"
+)
+
+// amountReader reads the amount of a row one way, returning the column it came from.
+// found is false when the row doesn't hold an amount that way.
+type amountReader func(data map[string]string, preset *Preset) (amount int64, column string, found bool, err error)
+
+// Readers of each amount strategy
+var amountReaders = map[string]amountReader{
+	amountSeparate:  separateAmount,
+	amountSigned:    signedAmount,
+	amountSuffix:    suffixAmount,
+	amountIndicator: indicatorAmount,
+	amountDirection: directionAmount,
+}
+
+// Names of the amount strategies, for messages
+var amountStrategyNames = []string{amountSeparate, amountSigned, amountSuffix, amountIndicator, amountDirection}
+
+// validateAmountStrategies checks that every strategy of a preset is known and has its columns set
+func validateAmountStrategies(preset *Preset) error {
+	columns := preset.Columns
+	for _, strategy := range preset.AmountStrategies {
+		var missing string
+		switch strategy {
+		case amountSeparate:
+			if columns.Debit == "" && columns.Credit == "" {
+				missing = "a debit or credit column"
+			}
+		case amountSigned, amountSuffix:
+			if columns.Amount == "" {
+				missing = "an amount column"
+			}
+		case amountIndicator:
+			if columns.Amount == "" || columns.Indicator == "" {
+				missing = "amount and indicator columns"
+			}
+		case amountDirection:
+			if columns.Amount == "" || columns.Direction == "" {
+				missing = "amount and direction columns"
+			}
+		default:
+			return fmt.Errorf("unknown amount strategy %q, expected one of %s", strategy, strings.Join(amountStrategyNames, ", "))
+		}
+		if missing != "" {
+			return fmt.Errorf("the %s amount strategy needs %s", strategy, missing)
+		}
+	}
+	return nil
+}
+
+// readAmountStrategies reads the amount of a row with the first of the preset's strategies that finds
+// a valid one, recording which it was. It fails only when no strategy finds an amount and one of them
+// found an invalid one.
+func readAmountStrategies(t *transaction, data map[string]string, preset *Preset) error {
+	var firstErr error
+	for _, strategy := range preset.AmountStrategies {
+		amount, column, found, err := amountReaders[strategy](data, preset)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s amount: %s", strategy, err)
+			}
+			continue
+		}
+		if !found {
+			continue
+		}
+		setAmountFrom(t, amount, data[column])
+		t.amountStrategy = strategy
+		return nil
+	}
+	return firstErr
+}
+
+// setAmountFrom gives a transaction its amount and type, keeping the cell it was read from
+func setAmountFrom(t *transaction, amount int64, cell string) {
+	t.setAmount(amount)
+	t.TransactionType = creditType
+	t.rawCredit, t.rawDebit = cell, ""
+	if amount < 0 {
+		t.TransactionType = debitType
+		t.rawCredit, t.rawDebit = "", cell
+	}
+}
+
+// separateAmount reads unsigned amounts from the debit and credit columns, a debit taking precedence
+func separateAmount(data map[string]string, preset *Preset) (int64, string, bool, error) {
+	columns := preset.Columns
+	if columns.Debit != "" && hasValue(data[columns.Debit]) {
+		amount, err := parseAmount(data[columns.Debit])
+		return -abs(amount), columns.Debit, err == nil, err
+	}
+	if columns.Credit != "" && hasValue(data[columns.Credit]) {
+		amount, err := parseAmount(data[columns.Credit])
+		return abs(amount), columns.Credit, err == nil, err
+	}
+	return 0, "", false, nil
+}
+
+// signedAmount reads a signed amount from the amount column
+func signedAmount(data map[string]string, preset *Preset) (int64, string, bool, error) {
+	column := preset.Columns.Amount
+	if column == "" || !hasValue(data[column]) {
+		return 0, "", false, nil
+	}
+	amount, err := parseAmount(data[column])
+	return amount, column, err == nil, err
+}
+
+// suffixAmount reads an amount from the amount column only when it has a CR or DR suffix
+func suffixAmount(data map[string]string, preset *Preset) (int64, string, bool, error) {
+	column := preset.Columns.Amount
+	value := strings.ToUpper(strings.TrimSpace(data[column]))
+	if column == "" || !(strings.HasSuffix(value, "CR") || strings.HasSuffix(value, "DR")) {
+		return 0, "", false, nil
+	}
+	return signedAmount(data, preset)
+}
+
+// indicatorAmount reads an unsigned amount from the amount column, signed by the indicator column
+func indicatorAmount(data map[string]string, preset *Preset) (int64, string, bool, error) {
+	amount, column, found, err := signedAmount(data, preset)
+	if !found || preset.Columns.Indicator == "" {
+		return 0, "", false, err
+	}
+	indicator := data[preset.Columns.Indicator]
+	switch {
+	case matchesAny(indicator, preset.DebitIndicators, defaultDebitIndicators):
+		return -abs(amount), column, true, nil
+	case matchesAny(indicator, preset.CreditIndicators, defaultCreditIndicators):
+		return abs(amount), column, true, nil
+	}
+	return 0, "", false, fmt.Errorf("unknown debit/credit indicator %q in %s", indicator, preset.Columns.Indicator)
+}
+
+// directionAmount reads an unsigned amount from the amount column, signed by the yes/no direction column
+func directionAmount(data map[string]string, preset *Preset) (int64, string, bool, error) {
+	amount, column, found, err := signedAmount(data, preset)
+	if !found || preset.Columns.Direction == "" {
+		return 0, "", false, err
+	}
+	direction := data[preset.Columns.Direction]
+	switch {
+	case matchesAny(direction, preset.DirectionDebit, defaultDirectionDebit):
+		return -abs(amount), column, true, nil
+	case matchesAny(direction, preset.DirectionCredit, defaultDirectionCredit):
+		return abs(amount), column, true, nil
+	}
+	return 0, "", false, fmt.Errorf("unknown debit direction %q in %s", direction, preset.Columns.Direction)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReadAmountStrategies(t *testing.T) {
+	preset := &Preset{Columns: ColumnMapping{Debit: "Debit", Credit: "Credit", Amount: "Amount", Indicator: "D/C"}}
+	tests := []struct {
+		name         string
+		strategies   []string
+		row          map[string]string
+		wantAmount   int64
+		wantStrategy string
+		wantErr      bool
+	}{
+		{"first strategy wins", []string{amountSeparate, amountSigned},
+			map[string]string{"Debit": "1.00", "Amount": "-2.00"}, -100, amountSeparate, false},
+		{"falls back when the first finds nothing", []string{amountSeparate, amountSigned},
+			map[string]string{"Debit": " ", "Amount": "-2.00"}, -200, amountSigned, false},
+		{"suffix needs a suffix", []string{amountSuffix, amountIndicator},
+			map[string]string{"Amount": "3.00", "D/C": "D"}, -300, amountIndicator, false},
+		{"suffix", []string{amountSuffix, amountIndicator},
+			map[string]string{"Amount": "3.00 CR", "D/C": "D"}, 300, amountSuffix, false},
+		{"falls back past an invalid amount", []string{amountSigned, amountSeparate},
+			map[string]string{"Amount": "n/a", "Credit": "4.00"}, 400, amountSeparate, false},
+		{"invalid amount and nothing else", []string{amountSigned, amountSeparate},
+			map[string]string{"Amount": "n/a"}, 0, "", true},
+		{"no amount at all", []string{amountSigned, amountSeparate},
+			map[string]string{}, 0, "", false},
+	}
+	for _, tt := range tests {
+		preset.AmountStrategies = tt.strategies
+		tr := &transaction{Transform: &Transform{}}
+		err := readAmountStrategies(tr, tt.row, preset)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: got error %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tr.amount != tt.wantAmount || tr.amountStrategy != tt.wantStrategy {
+			t.Errorf("%s: got %d pence with %q, want %d with %q", tt.name, tr.amount, tr.amountStrategy, tt.wantAmount, tt.wantStrategy)
+		}
+	}
+}
+
+func TestAmountStrategyChosenPerFile(t *testing.T) {
+	// The first row settles on the suffix strategy, so the file's later debit column is ignored
+	statement := "Date,Debit,Credit,Amount\n" +
+		"01/06/2020,,,25.99 DR\n" +
+		"02/06/2020,4.00,,10.00 CR\n" +
+		"03/06/2020,4.00,,\n"
+	got := outputRows(transformStatement(t, statement, func(p *Preset) {
+		p.HeaderSignature = []string{"Date", "Debit"}
+		p.Columns = ColumnMapping{Date: "Date", Debit: "Debit", Credit: "Credit", Amount: "Amount"}
+		p.AmountStrategies = []string{amountSeparate, amountSuffix}
+	}))
+	want := []string{"01/06/2020,-25.99,,,,,Debit", "02/06/2020,10.00,,,,,Credit", "03/06/2020,,,,,,"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got rows %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+// Number of transactions sampled from the start of a statement to check the balance against
+const balanceSampleSize = 6
+
+// balanceCheck compares the first few amounts of a statement with the changes in its running balance,
+// to catch debits and credits being read the wrong way round
+type balanceCheck struct {
+	name     string
+	amounts  []int64
+	balances []int64
+	done     bool
+}
+
+// add samples a transaction, checking the samples once there are enough
+func (c *balanceCheck) add(t *transaction, balance string) {
+	if c.done || !t.hasAmount || !hasValue(balance) {
+		return
+	}
+	value, err := parseAmount(balance)
+	if err != nil {
+		return
+	}
+	c.amounts = append(c.amounts, t.amount)
+	c.balances = append(c.balances, value)
+	if len(c.amounts) == balanceSampleSize {
+		c.check()
+	}
+}
+
+// check warns if the balance moves against the amounts more often than with them. Statements may
+// list the newest transaction first, so both orders are tried.
+func (c *balanceCheck) check() {
+	if c.done || len(c.amounts) < 2 {
+		return
+	}
+	c.done = true
+	consistent, inverted := 0, 0
+	for i := 1; i < len(c.amounts); i++ {
+		oldestFirst := c.balances[i] - c.balances[i-1]
+		newestFirst := c.balances[i-1] - c.balances[i]
+		switch {
+		case oldestFirst == c.amounts[i] || newestFirst == c.amounts[i-1]:
+			consistent++
+		case oldestFirst == -c.amounts[i] || newestFirst == -c.amounts[i-1]:
+			inverted++
+		}
+	}
+	log.Debugf("Balance check of %s: %d consistent and %d inverted of %d changes", c.name, consistent, inverted, len(c.amounts)-1)
+	if inverted > consistent {
+		log.Criticalf("The running balance of %s moves the opposite way to the amounts, so debits and credits may be the wrong way round: check the debit, credit and indicator settings", c.name)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+)
+
+// coalesceTransactions merges transactions on the same date sharing the same value in the given field,
+// summing their amounts. Transactions with an empty key are left untouched.
+func coalesceTransactions(transactions []*transaction, field string) []*transaction {
+	var merged []*transaction
+	groups := map[string]*transaction{}
+	amounts := map[*transaction]int64{}
+
+	for _, t := range transactions {
+		key, _ := transformField(t.Transform, field)
+		if strings.TrimSpace(key) == "" {
+			merged = append(merged, t)
+			continue
+		}
+
+		if !t.hasAmount {
+			warnRow(t.lines[0], "Not coalescing transaction on line %d as it has no amount", t.lines[0])
+			merged = append(merged, t)
+			continue
+		}
+		amount := t.amount
+
+		groupKey := t.Date + "\x00" + key
+		first, ok := groups[groupKey]
+		if !ok {
+			groups[groupKey] = t
+			amounts[t] = amount
+			merged = append(merged, t)
+			continue
+		}
+
+		amounts[first] += amount
+		first.lines = append(first.lines, t.lines...)
+		log.Noticef("Coalesced transaction on line %d into line %d (%s %q on %s, lines %v)",
+			t.lines[0], first.lines[0], field, key, t.Date, first.lines)
+	}
+
+	for t, amount := range amounts {
+		if len(t.lines) == 1 {
+			continue
+		}
+		t.setAmount(amount)
+		t.TransactionType = creditType
+		if amount < 0 {
+			t.TransactionType = debitType
+		}
+	}
+
+	return merged
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Extensions of the config files presets are read from
+var configExtensions = []string{".json", ".yaml", ".yml", ".toml"}
+
+// readConfigFile reads a config file as JSON. YAML and TOML files, told apart by their extension,
+// are converted so every format has the same keys as the JSON one.
+func readConfigFile(path string) ([]byte, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	settings := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &settings)
+	case ".toml":
+		err = toml.Unmarshal(content, &settings)
+	default:
+		return content, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(settings)
+}
+
+// decodeConfig decodes a config read by readConfigFile, failing on settings the Preset doesn't have
+func decodeConfig(content []byte, preset *Preset) error {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(preset); err != nil {
+		return fmt.Errorf("%s", strings.TrimPrefix(err.Error(), "json: "))
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+)
+
+// countTransactions counts the transactions in a statement without transforming them. Rows are
+// skipped by the same rules as a transform, so the count matches the transactions a transform reads.
+func countTransactions(in input, preset *Preset, delimiter rune) (int, error) {
+	defer in.reader.Close()
+	log.Infof("Counting %s", in.name)
+	csvr := newStatementReader(in.reader, delimiter)
+
+	headers, err := readHeader(csvr, in.name, preset)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for {
+		row, err := csvr.Read()
+		if err == io.EOF {
+			break
+		}
+		if _, ok := err.(*csv.ParseError); ok {
+			continue
+		}
+		if err != nil {
+			return count, fmt.Errorf("%w reading %s: %s", ErrIO, in.name, err)
+		}
+		line, _ := csvr.FieldPos(0)
+		if isEndMarker(row, preset.EndMarkers) {
+			log.Debugf("Reached the footer of %s on line %d", in.name, line)
+			break
+		}
+		if isOversizedRow(row) {
+			continue
+		}
+		if matchesSignature(row, preset.HeaderSignature) {
+			if row, err = joinHeaderRows(csvr, row); err != nil {
+				return count, fmt.Errorf("%w reading the header on line %d of %s: %s", ErrNoHeader, line, in.name, err)
+			}
+			headers = sectionHeaders(headers, row, preset, line)
+			continue
+		}
+		data, _, err := mapRow(headers, row)
+		if err != nil || skipRow(row, data, preset, line) {
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
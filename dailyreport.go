@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// dailyEntry accumulates the transactions written for a single day
+type dailyEntry struct {
+	count   int
+	credits int64
+	debits  int64
+}
+
+// dailyTotals aggregates written transactions by day, keyed by ISO date.
+// Transactions without a usable date are gathered under an empty key.
+type dailyTotals map[string]*dailyEntry
+
+// Report of per-day totals, nil when not wanted
+var dailyReport dailyTotals
+
+// newDailyTotals creates an empty daily report
+func newDailyTotals() dailyTotals {
+	return dailyTotals{}
+}
+
+// add includes a transaction in the day's totals
+func (d dailyTotals) add(t *transaction) {
+	key := ""
+	if !t.date.IsZero() {
+		key = t.date.Format("2006-01-02")
+	}
+	entry, ok := d[key]
+	if !ok {
+		entry = &dailyEntry{}
+		d[key] = entry
+	}
+	entry.count++
+
+	if !t.hasAmount {
+		return
+	}
+	amount := t.amount
+	if amount < 0 {
+		entry.debits -= amount
+	} else {
+		entry.credits += amount
+	}
+}
+
+// write writes the report as CSV in date order, with any undated transactions on a final "unknown" line
+func (d dailyTotals) write(w io.Writer) error {
+	var days []string
+	for day := range d {
+		if day != "" {
+			days = append(days, day)
+		}
+	}
+	sort.Strings(days)
+	if _, ok := d[""]; ok {
+		days = append(days, "")
+	}
+
+	csvw := csv.NewWriter(w)
+	csvw.Write([]string{"Date", "Count", "Credits", "Debits", "Net"})
+	for _, day := range days {
+		entry := d[day]
+		label := day
+		if label == "" {
+			label = "unknown"
+		}
+		csvw.Write([]string{
+			label,
+			strconv.Itoa(entry.count),
+			formatAmount(entry.credits),
+			formatAmount(entry.debits),
+			formatAmount(entry.credits - entry.debits),
+		})
+	}
+	csvw.Flush()
+	return csvw.Error()
+}
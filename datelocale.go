@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+// dateNames are the month and weekday names of a language, months from January and weekdays from Sunday
+type dateNames struct {
+	months      [12]string
+	shortMonths [12]string
+	weekdays    [7]string
+}
+
+// Month and weekday names of the languages -datelocale supports besides English, keyed by language
+var localeDateNames = map[string]dateNames{
+	"de": {
+		months:      [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		shortMonths: [12]string{"Jan.", "Feb.", "März", "Apr.", "Mai", "Juni", "Juli", "Aug.", "Sept.", "Okt.", "Nov.", "Dez."},
+		weekdays:    [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+	},
+	"es": {
+		months:      [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		shortMonths: [12]string{"ene.", "feb.", "mar.", "abr.", "may.", "jun.", "jul.", "ago.", "sept.", "oct.", "nov.", "dic."},
+		weekdays:    [7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+	},
+	"fr": {
+		months:      [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		shortMonths: [12]string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."},
+		weekdays:    [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+	},
+	"it": {
+		months:      [12]string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"},
+		shortMonths: [12]string{"gen", "feb", "mar", "apr", "mag", "giu", "lug", "ago", "set", "ott", "nov", "dic"},
+		weekdays:    [7]string{"domenica", "lunedì", "martedì", "mercoledì", "giovedì", "venerdì", "sabato"},
+	},
+	"nl": {
+		months:      [12]string{"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december"},
+		shortMonths: [12]string{"jan.", "feb.", "mrt.", "apr.", "mei", "jun.", "jul.", "aug.", "sep.", "okt.", "nov.", "dec."},
+		weekdays:    [7]string{"zondag", "maandag", "dinsdag", "woensdag", "donderdag", "vrijdag", "zaterdag"},
+	},
+}
+
+// Names of the date locale, nil to read and write dates with English names only
+var dateLocale *dateNames
+
+// Whether dates are written with the names of the date locale too
+var localizeOutputDates bool
+
+// Words of a date, with the full stop some languages end abbreviations with
+var dateWord = regexp.MustCompile(`\pL+\.?`)
+
+// parseDateLocale finds the names for a language tag such as "fr" or "fr-CA", nil for English
+func parseDateLocale(spec string) (*dateNames, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	tag, err := language.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -datelocale %q: %s", spec, err)
+	}
+	base, _ := tag.Base()
+	if base.String() == "en" {
+		return nil, nil
+	}
+	names, ok := localeDateNames[base.String()]
+	if !ok {
+		var supported []string
+		for lang := range localeDateNames {
+			supported = append(supported, lang)
+		}
+		sort.Strings(supported)
+		return nil, fmt.Errorf("no date names for -datelocale %q, expected en or one of %s", spec, strings.Join(supported, ", "))
+	}
+	return &names, nil
+}
+
+// englishDate replaces the month and weekday names of the date locale in a date with English ones,
+// so Go layouts such as "2 January 2006" read it. Words it doesn't know are left for English.
+func (n *dateNames) englishDate(value string) string {
+	return dateWord.ReplaceAllStringFunc(value, func(word string) string {
+		for _, candidate := range []string{word, strings.TrimSuffix(word, ".")} {
+			for i := range n.months {
+				switch {
+				case strings.EqualFold(candidate, n.months[i]):
+					return time.Month(i+1).String() + strings.TrimPrefix(word, candidate)
+				case strings.EqualFold(candidate, n.shortMonths[i]) || strings.EqualFold(candidate, strings.TrimSuffix(n.shortMonths[i], ".")):
+					return time.Month(i + 1).String()[:3]
+				}
+			}
+			for i := range n.weekdays {
+				if strings.EqualFold(candidate, n.weekdays[i]) {
+					return time.Weekday(i).String() + strings.TrimPrefix(word, candidate)
+				}
+			}
+		}
+		return word
+	})
+}
+
+// localDate replaces the English month and weekday names of a formatted date with those of the date locale
+func (n *dateNames) localDate(value string) string {
+	return dateWord.ReplaceAllStringFunc(value, func(word string) string {
+		for i := range n.months {
+			month := time.Month(i + 1).String()
+			switch word {
+			case month:
+				return n.months[i]
+			case month[:3]:
+				return n.shortMonths[i]
+			}
+		}
+		for i := range n.weekdays {
+			if word == time.Weekday(i).String() {
+				return n.weekdays[i]
+			}
+		}
+		return word
+	})
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDateLocale(t *testing.T) {
+	tests := []struct {
+		spec     string
+		wantNil  bool
+		wantErr  bool
+		wantJune string
+	}{
+		{"", true, false, ""},
+		{"en-GB", true, false, ""},
+		{"fr", false, false, "juin"},
+		{"fr-CA", false, false, "juin"},
+		{"de", false, false, "Juni"},
+		{"pt", false, true, ""},
+		{"not a tag!", false, true, ""},
+	}
+	for _, tt := range tests {
+		names, err := parseDateLocale(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: got error %v, want error %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if (names == nil) != (tt.wantNil || tt.wantErr) {
+			t.Errorf("%q: got names %v", tt.spec, names)
+			continue
+		}
+		if names != nil && names.months[5] != tt.wantJune {
+			t.Errorf("%q: got June as %q, want %q", tt.spec, names.months[5], tt.wantJune)
+		}
+	}
+}
+
+func TestParseLocaleDates(t *testing.T) {
+	french, err := parseDateLocale("fr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	german, err := parseDateLocale("de")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		locale *dateNames
+		value  string
+		layout string
+		want   time.Time
+	}{
+		{french, "3 janvier 2024", "2 January 2006", time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)},
+		{french, "3 JANVIER 2024", "", time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)},
+		{french, "14 févr. 2024", "2 Jan 2006", time.Date(2024, 2, 14, 0, 0, 0, 0, time.UTC)},
+		{french, "jeudi 1 août 2024", "Monday 2 January 2006", time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)},
+		{french, "3 January 2024", "", time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)},
+		{german, "3. März 2024", "2. January 2006", time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		setForTest(t, &dateLocale, tt.locale)
+		got, err := parseDate(tt.value, tt.layout)
+		if err != nil {
+			t.Errorf("%q: %s", tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("%q: got %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestFormatLocaleDate(t *testing.T) {
+	french, err := parseDateLocale("fr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	setForTest(t, &dateLocale, french)
+	date := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		localize bool
+		layout   string
+		want     string
+	}{
+		{false, "2 January 2006", "1 February 2024"},
+		{true, "2 January 2006", "1 février 2024"},
+		{true, "Monday 02/01/2006", "jeudi 01/02/2024"},
+	}
+	for _, tt := range tests {
+		setForTest(t, &localizeOutputDates, tt.localize)
+		if got := formatDate(date, tt.layout); got != tt.want {
+			t.Errorf("%s localized %v: got %q, want %q", tt.layout, tt.localize, got, tt.want)
+		}
+	}
+}
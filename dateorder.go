@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// numericDate matches dates written as three numbers, such as 02/01/2006 or 1-2-06
+var numericDate = regexp.MustCompile(`^\s*(\d{1,2})[/.-](\d{1,2})[/.-](\d{2}|\d{4})\s*$`)
+
+// dateOrderCheck watches the numeric dates of a statement for a switch between day first and
+// month first, as happens when a spreadsheet re-saves part of a file
+type dateOrderCheck struct {
+	name string
+	// First lines with a date that can only be day first or only be month first
+	dayFirstLine   int
+	monthFirstLine int
+	// Dates that read as valid either way
+	ambiguous int
+	warned    bool
+}
+
+// add checks a date, returning an error wrapping ErrBadRow for a switch in the date order under -strict
+func (c *dateOrderCheck) add(value string, line int) error {
+	parts := numericDate.FindStringSubmatch(value)
+	if parts == nil {
+		return nil
+	}
+	first, _ := strconv.Atoi(parts[1])
+	second, _ := strconv.Atoi(parts[2])
+	switch {
+	case first > 12 && second <= 12:
+		if c.dayFirstLine == 0 {
+			c.dayFirstLine = line
+		}
+	case second > 12 && first <= 12:
+		if c.monthFirstLine == 0 {
+			c.monthFirstLine = line
+		}
+	case first != second:
+		log.Debugf("Date %q on line %d reads as valid with either the day or the month first", value, line)
+		c.ambiguous++
+	}
+
+	if c.warned || c.dayFirstLine == 0 || c.monthFirstLine == 0 {
+		return nil
+	}
+	c.warned = true
+	message := fmt.Sprintf("Dates in %s switch between day first (line %d) and month first (line %d), so some of them are probably misread",
+		c.name, c.dayFirstLine, c.monthFirstLine)
+	if strict {
+		return fmt.Errorf("%w: line %d: %s", ErrBadRow, line, message)
+	}
+	warnRow(line, "%s", message)
+	return nil
+}
+
+// report logs how many dates could be read either way
+func (c *dateOrderCheck) report() {
+	if c.ambiguous > 0 {
+		log.Infof("%d dates in %s read as valid with either the day or the month first", c.ambiguous, c.name)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Range of dates of the transactions written, either end zero when it's open
+var fromDate, toDate time.Time
+
+// parseDateRange works out the range of dates to write from -from and -to, or from -lastdays,
+// which counts back from -asof, today when it's empty
+func parseDateRange(from, to string, lastDays int, asOf string) (time.Time, time.Time, error) {
+	var first, last time.Time
+	var err error
+	if lastDays < 0 {
+		return first, last, fmt.Errorf("-lastdays must not be negative")
+	}
+	if lastDays > 0 {
+		if from != "" || to != "" {
+			return first, last, fmt.Errorf("use either -lastdays or -from and -to")
+		}
+		last = dayOf(time.Now())
+		if asOf != "" {
+			if last, err = parseDate(asOf, ""); err != nil {
+				return first, last, fmt.Errorf("invalid -asof: %s", err)
+			}
+		}
+		return last.AddDate(0, 0, 1-lastDays), last, nil
+	}
+	if asOf != "" {
+		return first, last, fmt.Errorf("-asof is only used with -lastdays")
+	}
+	if from != "" {
+		if first, err = parseDate(from, ""); err != nil {
+			return first, last, fmt.Errorf("invalid -from: %s", err)
+		}
+	}
+	if to != "" {
+		if last, err = parseDate(to, ""); err != nil {
+			return first, last, fmt.Errorf("invalid -to: %s", err)
+		}
+	}
+	if !first.IsZero() && !last.IsZero() && last.Before(first) {
+		return first, last, fmt.Errorf("-to %s is before -from %s", to, from)
+	}
+	return first, last, nil
+}
+
+// dayOf drops the time of day, keeping the date as it reads where the time was taken
+func dayOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// outsideDateRange reports whether a transaction is dated outside -from and -to, or -lastdays.
+// Transactions whose date couldn't be read are kept.
+func outsideDateRange(t *transaction) bool {
+	if fromDate.IsZero() && toDate.IsZero() {
+		return false
+	}
+	if t.date.IsZero() {
+		warnRow(t.lines[0], "Unable to compare the date %q on line %d with the date range, keeping it", t.Date, t.lines[0])
+		return false
+	}
+	day := dayOf(t.date)
+	return (!fromDate.IsZero() && day.Before(fromDate)) || (!toDate.IsZero() && day.After(toDate))
+}
+
+// dateRangeEnd describes an end of the date range for the log
+func dateRangeEnd(date time.Time) string {
+	if date.IsZero() {
+		return "any date"
+	}
+	return date.Format("2006-01-02")
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fallbackDateFormats are tried in turn to make sense of dates when the preset doesn't give a date format
+var fallbackDateFormats = []string{
+	"02/01/2006",
+	"2006-01-02",
+	"02-01-2006",
+	"02/01/06",
+	"02 Jan 2006",
+	"2 Jan 2006",
+	"02 January 2006",
+	"2 January 2006",
+	"Jan 2 2006",
+	"Jan 2, 2006",
+	"January 2 2006",
+	"January 2, 2006",
+}
+
+// ordinalDay matches a day of the month written with an ordinal suffix, as in "1st Jan 2024"
+var ordinalDay = regexp.MustCompile(`(?i)\b(\d{1,2})(st|nd|rd|th)\b`)
+
+// Date formats of timestamps counting from the Unix epoch
+const (
+	dateFormatUnix      = "unix"
+	dateFormatUnixMilli = "unixmilli"
+)
+
+// Time zone Unix timestamps are turned into dates in
+var timestampLocation = time.UTC
+
+// errNotTimestamp is returned for a Unix timestamp date that isn't a number
+var errNotTimestamp = errors.New("not a Unix timestamp")
+
+// isTimestampFormat reports whether dates are Unix timestamps
+func isTimestampFormat(layout string) bool {
+	return layout == dateFormatUnix || layout == dateFormatUnixMilli
+}
+
+// parseDate parses a statement date with the given layout, or with the fallback layouts when none is given
+func parseDate(value string, layout string) (time.Time, error) {
+	// Go layouts have no way of describing ordinal suffixes, so drop them
+	value = ordinalDay.ReplaceAllString(strings.TrimSpace(value), "$1")
+	if dateLocale != nil {
+		value = dateLocale.englishDate(value)
+	}
+	if isTimestampFormat(layout) {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: %q", errNotTimestamp, value)
+		}
+		if layout == dateFormatUnixMilli {
+			return time.UnixMilli(n).In(timestampLocation), nil
+		}
+		return time.Unix(n, 0).In(timestampLocation), nil
+	}
+	if layout != "" {
+		return time.Parse(layout, value)
+	}
+	for _, fallback := range fallbackDateFormats {
+		if date, err := time.Parse(fallback, value); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised date %q", value)
+}
+
+// formatDate formats a date for the output, with the names of the date locale when asked to
+func formatDate(date time.Time, layout string) string {
+	formatted := date.Format(layout)
+	if dateLocale != nil && localizeOutputDates {
+		formatted = dateLocale.localDate(formatted)
+	}
+	return formatted
+}
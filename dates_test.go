@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/baloo32/xerobanktransform/internal/statementgen"
+)
+
+func FuzzParseDate(f *testing.F) {
+	for _, date := range statementgen.Dates {
+		for _, layout := range []string{"", "02/01/2006", dateFormatUnix, dateFormatUnixMilli} {
+			f.Add(date, layout)
+		}
+	}
+	f.Fuzz(func(t *testing.T, value, layout string) {
+		date, err := parseDate(value, layout)
+		if err != nil || isTimestampFormat(layout) {
+			return
+		}
+		// A date parsed from text is written so that it reads back the same
+		formatted := formatDate(date, outputDateFormat)
+		again, err := parseDate(formatted, outputDateFormat)
+		if err != nil {
+			t.Fatalf("date %q was written as %q, which doesn't parse: %s", value, formatted, err)
+		}
+		if y, m, d := date.Date(); again.Year() != y || again.Month() != m || again.Day() != d {
+			t.Fatalf("date %q was written as %q, which reads back as %s", value, formatted, again)
+		}
+	})
+}
+
+func TestParseTimestampDates(t *testing.T) {
+	tests := []struct {
+		value   string
+		layout  string
+		want    time.Time
+		wantErr bool
+	}{
+		{"1591000000", dateFormatUnix, time.Date(2020, 6, 1, 8, 26, 40, 0, time.UTC), false},
+		{"1591000000123", dateFormatUnixMilli, time.Date(2020, 6, 1, 8, 26, 40, 123e6, time.UTC), false},
+		{"-1000", dateFormatUnixMilli, time.Date(1969, 12, 31, 23, 59, 59, 0, time.UTC), false},
+		{"9300000000000", dateFormatUnixMilli, time.Unix(9300000000, 0), false},
+		{"-9300000000000", dateFormatUnixMilli, time.Unix(-9300000000, 0), false},
+		{"1591000000.5", dateFormatUnix, time.Time{}, true},
+		{"01/06/2020", dateFormatUnixMilli, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseDate(tt.value, tt.layout)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q as %s: got error %v, want error %v", tt.value, tt.layout, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			if !errors.Is(err, errNotTimestamp) {
+				t.Errorf("%q as %s: got error %v, want %v", tt.value, tt.layout, err, errNotTimestamp)
+			}
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("%q as %s: got %s, want %s", tt.value, tt.layout, got, tt.want)
+		}
+	}
+}
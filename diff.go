@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// Columns left out of the comparison with a previous output, as they number or hash the rows and so
+// change for every row after one that is added or removed
+var diffIgnoredColumns = map[string]bool{"Index": true, "FITID": true}
+
+// outputRow is a row of a CSV output, with the identifier it is matched on between outputs
+type outputRow struct {
+	key   string
+	cells map[string]string
+}
+
+// outputDiff counts the rows told apart between two outputs
+type outputDiff struct {
+	added, removed, changed int
+}
+
+// readOutputRows reads a CSV output, keying each row by a hash of its date and reference along with
+// how many rows before it share them, so an amount corrected at the source shows as a change
+func readOutputRows(r io.Reader) ([]string, []outputRow, error) {
+	csvr := csv.NewReader(r)
+	csvr.FieldsPerRecord = -1
+	headers, err := csvr.Read()
+	if err == io.EOF {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rows []outputRow
+	seen := map[string]int{}
+	for {
+		record, err := csvr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		cells := map[string]string{}
+		for i, header := range headers {
+			if i < len(record) {
+				cells[header] = record[i]
+			}
+		}
+		date, reference := cells[xeroCSVHeaders[0]], cells["Reference"]
+		occurrence := seen[date+"\x00"+reference]
+		seen[date+"\x00"+reference]++
+		rows = append(rows, outputRow{key: hashKey(date, reference, strconv.Itoa(occurrence)), cells: cells})
+	}
+	return headers, rows, nil
+}
+
+// sameRow reports whether two rows hold the same values, other than in the ignored columns
+func sameRow(headers []string, a, b outputRow) bool {
+	for _, header := range headers {
+		if !diffIgnoredColumns[header] && a.cells[header] != b.cells[header] {
+			return false
+		}
+	}
+	return true
+}
+
+// diffOutputs writes the rows added, removed and changed in the current output since the previous one,
+// as CSV with a leading Change column of "added", "removed", or "was" followed by "now" for a change.
+// Added and changed rows come in the order of the current output, then removed rows in the previous one's.
+func diffOutputs(previousPath string, currentPath string, w io.Writer) (outputDiff, error) {
+	var diff outputDiff
+	previousFile, err := os.Open(previousPath)
+	if err != nil {
+		return diff, err
+	}
+	defer previousFile.Close()
+	_, previous, err := readOutputRows(previousFile)
+	if err != nil {
+		return diff, fmt.Errorf("unable to read %s: %s", previousPath, err)
+	}
+	currentFile, err := os.Open(currentPath)
+	if err != nil {
+		return diff, err
+	}
+	defer currentFile.Close()
+	headers, current, err := readOutputRows(currentFile)
+	if err != nil {
+		return diff, fmt.Errorf("unable to read %s: %s", currentPath, err)
+	}
+
+	previousByKey := map[string]outputRow{}
+	for _, row := range previous {
+		previousByKey[row.key] = row
+	}
+	currentKeys := map[string]bool{}
+
+	csvw := csv.NewWriter(w)
+	write := func(change string, row outputRow) {
+		record := []string{change, row.key}
+		for _, header := range headers {
+			record = append(record, row.cells[header])
+		}
+		csvw.Write(record)
+	}
+	csvw.Write(append([]string{"Change", "Key"}, headers...))
+	for _, row := range current {
+		currentKeys[row.key] = true
+		old, ok := previousByKey[row.key]
+		switch {
+		case !ok:
+			diff.added++
+			write("added", row)
+		case !sameRow(headers, old, row):
+			diff.changed++
+			write("was", old)
+			write("now", row)
+		}
+	}
+	for _, row := range previous {
+		if !currentKeys[row.key] {
+			diff.removed++
+			write("removed", row)
+		}
+	}
+	csvw.Flush()
+	return diff, csvw.Error()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/transform"
+)
+
+// What to do with characters the output encoding can't represent
+const (
+	// Fail the run
+	unencodableFail = "fail"
+	// Write the charset's replacement character instead
+	unencodableReplace = "replace"
+)
+
+// Encoder of the output files, nil to write them as UTF-8
+var outputEncoder *encoding.Encoder
+
+// newOutputEncoder finds the encoder for a charset name such as "windows-1252", nil for UTF-8
+func newOutputEncoder(charset string, unencodable string) (*encoding.Encoder, error) {
+	if unencodable != unencodableFail && unencodable != unencodableReplace {
+		return nil, fmt.Errorf("unknown -unencodable %q, expected %s or %s", unencodable, unencodableFail, unencodableReplace)
+	}
+	switch strings.ToLower(strings.TrimSpace(charset)) {
+	case "", "utf-8", "utf8":
+		return nil, nil
+	}
+	enc, err := ianaindex.IANA.Encoding(charset)
+	if err != nil || enc == nil {
+		return nil, fmt.Errorf("unsupported output encoding %q", charset)
+	}
+	encoder := enc.NewEncoder()
+	if unencodable == unencodableReplace {
+		encoder = encoding.ReplaceUnsupported(encoder)
+	}
+	return encoder, nil
+}
+
+// encodeOutput wraps an output file so what is written to it is encoded with the output encoding.
+// The encoder is closed when the file is committed, writing out what it still holds.
+func encodeOutput(f *outputFile) io.WriteCloser {
+	if outputEncoder == nil {
+		return f
+	}
+	f.encoder = transform.NewWriter(f.File, outputEncoder)
+	return f.encoder
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+)
+
+// Categories of errors stopping a run, wrapped by the errors returned so the exit code and
+// the JSON summary can tell them apart
+var (
+	// The input has no rows at all
+	ErrEmptyInput = errors.New("input is empty")
+	// The header row, or the section marker before it, isn't in the input
+	ErrNoHeader = errors.New("header row not found")
+	// Columns the mapping reads amounts or dates from aren't in the header
+	ErrMissingColumns = errors.New("mapped columns missing")
+	// A row can't be transformed and the failfast strategy is in use
+	ErrBadRow = errors.New("bad row")
+	// Reading the input or writing the output failed
+	ErrIO = errors.New("i/o error")
+)
+
+// Exit codes for the error categories
+const (
+	exitNoHeader       = 5
+	exitBadRow         = 6
+	exitIO             = 7
+	exitMissingColumns = 8
+)
+
+// exitCode picks the exit code for an error stopping the run
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, ErrEmptyInput):
+		return exitEmptyInput
+	case errors.Is(err, ErrNoHeader):
+		return exitNoHeader
+	case errors.Is(err, ErrMissingColumns):
+		return exitMissingColumns
+	case errors.Is(err, ErrBadRow):
+		return exitBadRow
+	case errors.Is(err, ErrIO):
+		return exitIO
+	}
+	return 1
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Source line whose transform is explained, none when zero
+var explainLine int
+
+// Where explanations are written
+var explainOutput io.Writer = os.Stdout
+
+// errExplained stops the run once the explained line has been dealt with
+var errExplained = errors.New("line explained")
+
+// explaining reports whether a line is the one being explained
+func explaining(line int) bool {
+	return explainLine > 0 && line == explainLine
+}
+
+// explainf adds a step to the explanation of a line, if it is the one being explained
+func explainf(line int, format string, args ...interface{}) {
+	if explaining(line) {
+		fmt.Fprintf(explainOutput, "- "+format+"\n", args...)
+	}
+}
+
+// explainedSkip explains why a line is skipped, reporting whether it was the one being explained
+func explainedSkip(line int, format string, args ...interface{}) bool {
+	explainf(line, format, args...)
+	return explaining(line)
+}
+
+// explainStep runs one step of preparing a transaction, explaining any fields it changed
+func explainStep(t *transaction, line int, step string, apply func()) {
+	if !explaining(line) {
+		apply()
+		return
+	}
+	before := *t.Transform
+	apply()
+	for _, name := range transformFieldNames {
+		was, _ := transformField(&before, name)
+		now, _ := transformField(t.Transform, name)
+		if was != now {
+			explainf(line, "%s changed %s from %q to %q", step, name, was, now)
+		}
+	}
+}
+
+// explainSources says which source columns a text field is joined from
+func explainSources(line int, field string, data map[string]string, columns []string) {
+	if len(columns) == 0 {
+		explainf(line, "%s has no source columns", field)
+		return
+	}
+	var cells []string
+	for _, column := range columns {
+		cells = append(cells, fmt.Sprintf("%s=%q", column, columnValue(data, column)))
+	}
+	explainf(line, "%s joined from %s", field, strings.Join(cells, ", "))
+}
+
+// explainResult lists the fields a transaction is written with, saying why any are empty
+func explainResult(t *transaction, preset *Preset) {
+	line := t.lines[0]
+	if !explaining(line) {
+		return
+	}
+	fmt.Fprintf(explainOutput, "Written as:\n")
+	reasons := map[string]string{
+		"Date":            "the date column " + preset.Columns.Date + " is empty",
+		"Amount":          "no amount column held a value",
+		"Payee":           "its source columns are empty or not set",
+		"Description":     "its source columns are empty or not set",
+		"Reference":       "its source columns are empty or not set",
+		"ChequeNumber":    "its source columns are empty or not set",
+		"TransactionType": "there is no amount",
+	}
+	for _, name := range transformFieldNames {
+		value, _ := transformField(t.Transform, name)
+		if strings.TrimSpace(value) == "" {
+			fmt.Fprintf(explainOutput, "  %s: empty, as %s\n", name, reasons[name])
+			continue
+		}
+		fmt.Fprintf(explainOutput, "  %s: %q\n", name, value)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// extraOutput is a further output mapped with its own config from the same read of the input
+type extraOutput struct {
+	path   string
+	preset *Preset
+	out    transactionWriter
+	// Transactions held back until every input is read
+	pending []*transaction
+}
+
+// Further outputs written alongside the main one
+var extraOutputs []*extraOutput
+
+// openExtraOutput creates the further output described by an outfile=config pair. The input is
+// only read once, so the settings used to find its header come from the main preset.
+func openExtraOutput(spec string, main *Preset) (*extraOutput, error) {
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid -also %q, expected outfile=config", spec)
+	}
+	preset, err := loadPresetFile(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	preset.Delimiter = main.Delimiter
+	preset.HeaderSignature = main.HeaderSignature
+	preset.SectionMarkers = main.SectionMarkers
+	preset.SkipToMarker = main.SkipToMarker
+	preset.EndMarkers = main.EndMarkers
+	preset.HeaderAliases = main.HeaderAliases
+
+	file, err := createOutputFile(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	out, err := newTransactionWriter(outputFormats[0], encodeOutput(file))
+	if err != nil {
+		return nil, err
+	}
+	if err := out.WriteHeader(); err != nil {
+		return nil, err
+	}
+	return &extraOutput{path: parts[0], preset: &preset, out: out}, nil
+}
+
+// add maps a row with the output's own config and writes it, or holds it back
+func (e *extraOutput) add(data map[string]string, name string, line int) error {
+	t, err := buildTransform(data, e.preset, line)
+	if err != nil {
+		warnRow(line, "Not writing line %d to %s: %s", line, e.path, err)
+		return nil
+	}
+	t.index = summary.Read
+	t.source = sourceName(name)
+	if !prepareTransaction(t, data, e.preset, line) {
+		return nil
+	}
+	if filter != nil && !filter.match(t) {
+		return nil
+	}
+	if holdBack() {
+		e.pending = append(e.pending, t)
+		return nil
+	}
+	return e.write(t)
+}
+
+// write writes a transaction to the output
+func (e *extraOutput) write(t *transaction) error {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	if outputClosed {
+		return errOutputClosed
+	}
+	if summary.Outputs == nil {
+		summary.Outputs = map[string]int{}
+	}
+	summary.Outputs[e.path]++
+	if err := e.out.Write(t); err != nil {
+		return err
+	}
+	return e.out.Flush()
+}
+
+// writePending writes the held back transactions once every input has been read
+func (e *extraOutput) writePending(ctx context.Context) error {
+	for _, t := range arrangePending(e.pending) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := e.write(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
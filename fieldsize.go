@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Largest statement cell read, in bytes, no limit when zero. A corrupt export with an unterminated
+// quote would otherwise be read into memory whole as a single field.
+var maxFieldSize = 1 << 20
+
+// Stands in for the rest of a row cut short for holding a field larger than maxFieldSize
+const oversizedField = "\x00field too large\x00"
+
+// fieldSizeGuard cuts short statement rows holding a field larger than the limit, before the CSV reader
+// buffers them. Quotes are followed as the CSV reader does, so delimiters and line breaks inside quoted
+// fields don't end them. The rest of the row, up to the next line break, is dropped and replaced by
+// oversizedField, closing any quote left open so reading carries on from the next line.
+type fieldSizeGuard struct {
+	r   *bufio.Reader
+	max int
+	// Field delimiter, zero when it takes more than a byte and only line breaks end fields
+	delimiter byte
+	// Bytes of the current field, whether it started with a quote and whether that quote is still open
+	size     int
+	quoted   bool
+	inQuotes bool
+	// Dropping the rest of an oversized row
+	dropping bool
+	// Bytes to hand out before reading more
+	pending []byte
+}
+
+// newFieldSizeGuard guards a statement against fields larger than max bytes, returning it unguarded when max is zero
+func newFieldSizeGuard(r io.Reader, delimiter rune, max int) io.Reader {
+	if max <= 0 {
+		return r
+	}
+	g := &fieldSizeGuard{r: bufio.NewReader(r), max: max}
+	if delimiter < utf8.RuneSelf {
+		g.delimiter = byte(delimiter)
+	}
+	return g
+}
+
+func (g *fieldSizeGuard) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(g.pending) > 0 {
+			c := copy(p[n:], g.pending)
+			g.pending = g.pending[c:]
+			n += c
+			continue
+		}
+		// Hand out what has been read rather than wait for a slow input to fill p
+		if n > 0 && g.r.Buffered() == 0 {
+			return n, nil
+		}
+		b, err := g.r.ReadByte()
+		if err != nil {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+		if g.dropping {
+			if b != '\n' {
+				continue
+			}
+			g.dropping = false
+		}
+		switch {
+		case b == '"' && (g.quoted || g.size == 0):
+			g.quoted = true
+			g.inQuotes = !g.inQuotes
+		case !g.inQuotes && (b == '\n' || (g.delimiter != 0 && b == g.delimiter)):
+			g.size = 0
+			g.quoted = false
+			p[n] = b
+			n++
+			continue
+		}
+		g.size++
+		if g.size > g.max {
+			g.cut()
+			continue
+		}
+		p[n] = b
+		n++
+	}
+	return n, nil
+}
+
+// cut replaces the rest of the row with oversizedField and starts dropping it
+func (g *fieldSizeGuard) cut() {
+	g.pending = []byte(oversizedField)
+	if g.inQuotes {
+		g.pending = append(g.pending, '"')
+	}
+	g.size = 0
+	g.quoted = false
+	g.inQuotes = false
+	g.dropping = true
+}
+
+// isOversizedRow reports whether a row was cut short by the field size guard
+func isOversizedRow(row []string) bool {
+	for _, cell := range row {
+		if strings.Contains(cell, oversizedField) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFieldSizeGuard(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"small fields pass", "ab,cd\n\"e,f\",g\n", "ab,cd\n\"e,f\",g\n"},
+		{"quoted line breaks pass", "\"a\nb\",c\n", "\"a\nb\",c\n"},
+		{"oversized field", "ab,cdefghij,k\nl,m\n", "ab,cdefgh" + oversizedField + "\nl,m\n"},
+		{"oversized quoted field is closed", "\"abcdefgh,i\nj,k\n", "\"abcde" + oversizedField + "\"\nj,k\n"},
+		{"quoted delimiters count", "\"a,b,c,d\",e\n", "\"a,b,c" + oversizedField + "\"\n"},
+	}
+	for _, tt := range tests {
+		got, err := ioutil.ReadAll(newFieldSizeGuard(strings.NewReader(tt.input), ',', 6))
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOversizedFieldSkipped(t *testing.T) {
+	setForTest(t, &maxFieldSize, 100)
+	statement := statementHeader +
+		"01/06/2020,CARD,REF1,TESCO,4.01,,1\n" +
+		"02/06/2020,\"" + strings.Repeat("X", 1000) + ",REF2,AMAZON,2.00,,1\n" +
+		"03/06/2020,CARD,REF3,TESCO,1.00,,1\n"
+	got := outputRows(transformStatement(t, statement, nil))
+	want := []string{
+		"01/06/2020,-4.01,,TESCO,CARD REF1,,Debit",
+		"03/06/2020,-1.00,,TESCO,CARD REF3,,Debit",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got rows %q, want %q", got, want)
+	}
+	if summary.Skipped != 1 || len(summary.Warnings) != 1 {
+		t.Errorf("got %d skipped and %d warnings, want 1 and 1", summary.Skipped, len(summary.Warnings))
+	}
+}
+
+// slowReader hands out its content a byte at a time, then blocks as an input waiting for more would
+type slowReader struct {
+	content []byte
+	block   chan struct{}
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	if len(r.content) == 0 {
+		<-r.block
+		return 0, io.EOF
+	}
+	p[0] = r.content[0]
+	r.content = r.content[1:]
+	return 1, nil
+}
+
+func TestFieldSizeGuardDoesNotWait(t *testing.T) {
+	r := &slowReader{content: []byte("ab,cd\n"), block: make(chan struct{})}
+	defer close(r.block)
+	guard := newFieldSizeGuard(r, ',', 100)
+	var got []byte
+	buf := make([]byte, 64)
+	for len(got) < 6 {
+		n, err := guard.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, buf[:n]...)
+	}
+	if string(got) != "ab,cd\n" {
+		t.Errorf("got %q, want %q", got, "ab,cd\n")
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// filterExpr decides whether a transaction is written. Filters are written in a tiny language:
+//
+//	expr    = and { "||" and }
+//	and     = unary { "&&" unary }
+//	unary   = "!" unary | "(" expr ")" | compare
+//	compare = field op value
+//	op      = "==" | "!=" | "<" | "<=" | ">" | ">=" | "contains"
+//	value   = number | 'text' | "text"
+//
+// Fields are the Transform fields, e.g. Amount or Reference. Comparisons with a number compare amounts,
+// which only Amount and fields holding plain numbers have. Text comparisons ignore case.
+type filterExpr interface {
+	match(t *transaction) bool
+}
+
+type filterOr struct{ left, right filterExpr }
+
+func (f filterOr) match(t *transaction) bool { return f.left.match(t) || f.right.match(t) }
+
+type filterAnd struct{ left, right filterExpr }
+
+func (f filterAnd) match(t *transaction) bool { return f.left.match(t) && f.right.match(t) }
+
+type filterNot struct{ expr filterExpr }
+
+func (f filterNot) match(t *transaction) bool { return !f.expr.match(t) }
+
+// filterCompare compares a field with a literal value
+type filterCompare struct {
+	field string
+	op    string
+	text  string
+	// Literal in pence when it is a number
+	number   int64
+	isNumber bool
+}
+
+func (f filterCompare) match(t *transaction) bool {
+	value, _ := transformField(t.Transform, f.field)
+	if f.op == "contains" {
+		return strings.Contains(strings.ToLower(value), strings.ToLower(f.text))
+	}
+	if !f.isNumber {
+		equal := strings.EqualFold(strings.TrimSpace(value), f.text)
+		return equal == (f.op == "==")
+	}
+
+	amount, hasAmount := t.amount, t.hasAmount
+	if canonicalFieldName(f.field) != "Amount" {
+		var err error
+		amount, err = parseAmountWith(value, outputNumberFormat)
+		hasAmount = err == nil
+	}
+	if !hasAmount {
+		return f.op == "!="
+	}
+	switch f.op {
+	case "==":
+		return amount == f.number
+	case "!=":
+		return amount != f.number
+	case "<":
+		return amount < f.number
+	case "<=":
+		return amount <= f.number
+	case ">":
+		return amount > f.number
+	}
+	return amount >= f.number
+}
+
+// Filter selecting the transactions to write, nil when every transaction is written
+var filter filterExpr
+
+// parseFilter parses a filter expression, rejecting unknown fields and operators
+func parseFilter(expr string) (filterExpr, error) {
+	tokens, err := filterTokens(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	f, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in filter", p.tokens[p.pos].text)
+	}
+	return f, nil
+}
+
+// filterToken is a word, operator or literal of a filter expression
+type filterToken struct {
+	text string
+	// Whether the token is a quoted text literal
+	quoted bool
+}
+
+// filterTokens splits a filter expression into tokens
+func filterTokens(expr string) ([]filterToken, error) {
+	var tokens []filterToken
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'' || r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("unterminated text in filter")
+			}
+			tokens = append(tokens, filterToken{text: string(runes[i+1 : end]), quoted: true})
+			i = end + 1
+		case strings.ContainsRune("()", r):
+			tokens = append(tokens, filterToken{text: string(r)})
+			i++
+		case strings.ContainsRune("=!<>&|", r):
+			end := i + 1
+			if end < len(runes) && strings.ContainsRune("=&|", runes[end]) {
+				end++
+			}
+			tokens = append(tokens, filterToken{text: string(runes[i:end])})
+			i = end
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && !strings.ContainsRune("()=!<>&|'\"", runes[end]) {
+				end++
+			}
+			tokens = append(tokens, filterToken{text: string(runes[i:end])})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+// filterParser parses filter tokens by recursive descent
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+// peek returns the next unquoted token, or "" when there is none
+func (p *filterParser) peek() string {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].quoted {
+		return ""
+	}
+	return p.tokens[p.pos].text
+}
+
+// next consumes the next token
+func (p *filterParser) next() (filterToken, error) {
+	if p.pos >= len(p.tokens) {
+		return filterToken{}, fmt.Errorf("filter ends unexpectedly")
+	}
+	p.pos++
+	return p.tokens[p.pos-1], nil
+}
+
+func (p *filterParser) or() (filterExpr, error) {
+	left, err := p.and()
+	for err == nil && p.peek() == "||" {
+		p.pos++
+		var right filterExpr
+		if right, err = p.and(); err == nil {
+			left = filterOr{left, right}
+		}
+	}
+	return left, err
+}
+
+func (p *filterParser) and() (filterExpr, error) {
+	left, err := p.unary()
+	for err == nil && p.peek() == "&&" {
+		p.pos++
+		var right filterExpr
+		if right, err = p.unary(); err == nil {
+			left = filterAnd{left, right}
+		}
+	}
+	return left, err
+}
+
+func (p *filterParser) unary() (filterExpr, error) {
+	switch p.peek() {
+	case "!":
+		p.pos++
+		expr, err := p.unary()
+		return filterNot{expr}, err
+	case "(":
+		p.pos++
+		expr, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing ) in filter")
+		}
+		p.pos++
+		return expr, nil
+	}
+	return p.compare()
+}
+
+func (p *filterParser) compare() (filterExpr, error) {
+	field, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if field.quoted || transformFieldRef(&Transform{}, field.text) == nil {
+		return nil, fmt.Errorf("unknown field %q in filter, expected one of %s", field.text, strings.Join(transformFieldNames, ", "))
+	}
+	op, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	c := filterCompare{field: field.text, op: op.text}
+	switch op.text {
+	case "==", "!=", "<", "<=", ">", ">=", "contains":
+		if !op.quoted {
+			break
+		}
+		fallthrough
+	default:
+		return nil, fmt.Errorf("unknown operator %q in filter", op.text)
+	}
+
+	value, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	c.text = value.text
+	if !value.quoted {
+		if c.number, err = parseAmountWith(value.text, outputNumberFormat); err != nil {
+			return nil, fmt.Errorf("expected a number or quoted text after %s %s in filter, got %q", field.text, op.text, value.text)
+		}
+		c.isNumber = true
+	}
+	switch {
+	case op.text == "contains" && c.isNumber:
+		return nil, fmt.Errorf("contains needs quoted text in filter")
+	case op.text != "contains" && op.text != "==" && op.text != "!=" && !c.isNumber:
+		return nil, fmt.Errorf("%s needs a number in filter", op.text)
+	}
+	return c, nil
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// transactionFITID identifies a transaction by a hash of its date, amount, reference and position in
+// the source, so importers can tell a transaction they already have when a statement is imported again
+func transactionFITID(t *transaction) string {
+	return hashKey(t.Date, t.Amount, t.Reference, strconv.Itoa(t.index))
+}
+
+// hashKey hashes the parts of an identifier into a fixed length, stable hex string
+func hashKey(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
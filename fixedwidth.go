@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// fixedField places a Transform field in a fixed width line
+type fixedField struct {
+	// Transform field, e.g. Amount
+	Field string `json:"field"`
+	// Column the field starts at, counting from 1
+	Start int `json:"start"`
+	Width int `json:"width"`
+}
+
+// defaultFixedLayout places every field one after another
+var defaultFixedLayout = []fixedField{
+	{Field: "Date", Start: 1, Width: 10},
+	{Field: "Amount", Start: 11, Width: 12},
+	{Field: "Payee", Start: 23, Width: 30},
+	{Field: "Description", Start: 53, Width: 40},
+	{Field: "Reference", Start: 93, Width: 30},
+	{Field: "ChequeNumber", Start: 123, Width: 10},
+	{Field: "TransactionType", Start: 133, Width: 8},
+}
+
+// Layout of fixed width output lines
+var fixedLayout = defaultFixedLayout
+
+// loadFixedLayout reads a JSON list of fields with their start and width, in any order,
+// returning them in the order they appear on a line
+func loadFixedLayout(path string) ([]fixedField, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var layout []fixedField
+	if err := json.Unmarshal(content, &layout); err != nil {
+		return nil, fmt.Errorf("invalid fixed width layout %s: %s", path, err)
+	}
+	sort.SliceStable(layout, func(i, j int) bool { return layout[i].Start < layout[j].Start })
+	return layout, validateFixedLayout(layout)
+}
+
+// validateFixedLayout checks every field is known and no fields overlap. The fields must be in the
+// order they appear on a line, as they are written in turn.
+func validateFixedLayout(layout []fixedField) error {
+	if len(layout) == 0 {
+		return fmt.Errorf("fixed width layout has no fields")
+	}
+	end := 1
+	for _, f := range layout {
+		if transformFieldRef(&Transform{}, f.Field) == nil {
+			return fmt.Errorf("unknown field %q in fixed width layout, expected one of %s", f.Field, strings.Join(transformFieldNames, ", "))
+		}
+		if f.Start < 1 || f.Width < 1 {
+			return fmt.Errorf("field %s needs a start and width of at least 1", f.Field)
+		}
+		if f.Start < end {
+			return fmt.Errorf("field %s starting at %d overlaps the field before it", f.Field, f.Start)
+		}
+		end = f.Start + f.Width
+	}
+	return nil
+}
+
+// fixedTransactionWriter writes each transaction as a line of fixed width fields
+type fixedTransactionWriter struct {
+	w      *bufio.Writer
+	layout []fixedField
+}
+
+func (w *fixedTransactionWriter) WriteHeader() error {
+	return nil
+}
+
+func (w *fixedTransactionWriter) Write(t *transaction) error {
+	var line []rune
+	for _, f := range w.layout {
+		value, _ := transformField(t.Transform, f.Field)
+		value = strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+		if utf8.RuneCountInString(value) > f.Width {
+			warnRow(t.lines[0], "%s %q on line %d is wider than %d, truncating it", f.Field, value, t.lines[0], f.Width)
+			value = string([]rune(value)[:f.Width])
+		}
+		format := "%-*s"
+		// Amounts line up on the right
+		if canonicalFieldName(f.Field) == "Amount" {
+			format = "%*s"
+		}
+
+		for len(line) < f.Start-1 {
+			line = append(line, ' ')
+		}
+		line = append(line[:f.Start-1], []rune(fmt.Sprintf(format, f.Width, value))...)
+	}
+	_, err := io.WriteString(w.w, string(line)+"\n")
+	return err
+}
+
+func (w *fixedTransactionWriter) Flush() error {
+	return w.w.Flush()
+}
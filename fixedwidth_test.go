@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestFixedLayoutOutOfOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "layout.json")
+	layout := `[{"field": "Payee", "start": 11, "width": 5}, {"field": "Amount", "start": 17, "width": 6}, {"field": "Date", "start": 1, "width": 10}]`
+	if err := ioutil.WriteFile(path, []byte(layout), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fields, err := loadFixedLayout(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	w := &fixedTransactionWriter{w: bufio.NewWriter(&buf), layout: fields}
+	tr := &transaction{Transform: &Transform{Date: "01/02/2020", Payee: "TESCO", Amount: "-4.01"}, lines: []int{1}}
+	if err := w.Write(tr); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "01/02/2020TESCO  -4.01\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestValidateFixedLayout(t *testing.T) {
+	tests := []struct {
+		name    string
+		layout  []fixedField
+		wantErr bool
+	}{
+		{"default", defaultFixedLayout, false},
+		{"gaps", []fixedField{{"Date", 1, 10}, {"Amount", 15, 8}}, false},
+		{"overlapping", []fixedField{{"Date", 1, 10}, {"Amount", 10, 8}}, true},
+		{"unknown field", []fixedField{{"Date", 1, 10}, {"Balance", 11, 8}}, true},
+		{"no width", []fixedField{{"Date", 1, 0}}, true},
+		{"empty", nil, true},
+	}
+	for _, tt := range tests {
+		if err := validateFixedLayout(tt.layout); (err != nil) != tt.wantErr {
+			t.Errorf("%s: got error %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
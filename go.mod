@@ -1,9 +1,17 @@
 module github.com/baloo32/xerobanktransform
 
-go 1.13
+go 1.20
 
 require (
+	github.com/BurntSushi/toml v1.4.0
 	github.com/op/go-logging v0.0.0-20160315200505-970db520ece7
-	github.com/stretchr/pat v0.0.0-20140812192038-f7fe051f2b9b // indirect
 	github.com/stretchr/slog v0.0.0-20150331141657-117d3dd1018d
+	github.com/yeka/zip v0.0.0-20231116150916-03d6312748a9
+	golang.org/x/text v0.21.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/stretchr/pat v0.0.0-20140812192038-f7fe051f2b9b // indirect
+	golang.org/x/crypto v0.31.0 // indirect
 )
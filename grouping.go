@@ -0,0 +1,56 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// Transaction types to group the output by, in the order their groups are written, no grouping when empty.
+// Grouping holds every transaction in memory until the input is read.
+var groupByType stringList
+
+// Write a blank line between the groups of a CSV output, which Xero doesn't accept
+var groupSeparator bool
+
+// groupTransactions orders transactions by their group, types not listed in -groupbytype coming last
+// in name order, then by date within each group, the earliest first unless -reverse is set. Transactions without a
+// parsed date keep their order at the end of their group.
+func groupTransactions(transactions []*transaction) {
+	group := func(t *transaction) int {
+		for i, name := range groupByType {
+			if strings.EqualFold(strings.TrimSpace(name), t.TransactionType) {
+				return i
+			}
+		}
+		return len(groupByType)
+	}
+	sort.SliceStable(transactions, func(i, j int) bool {
+		a, b := transactions[i], transactions[j]
+		if ga, gb := group(a), group(b); ga != gb {
+			return ga < gb
+		}
+		if !strings.EqualFold(a.TransactionType, b.TransactionType) {
+			return strings.ToLower(a.TransactionType) < strings.ToLower(b.TransactionType)
+		}
+		if a.date.IsZero() || b.date.IsZero() {
+			return !a.date.IsZero() && b.date.IsZero()
+		}
+		if reverseOutput {
+			return a.date.After(b.date)
+		}
+		return a.date.Before(b.date)
+	})
+}
+
+// separatorWriter is a transactionWriter that can mark where one group of transactions ends
+type separatorWriter interface {
+	WriteSeparator() error
+}
+
+// writeGroupSeparator marks the end of a group on the writers that can
+func writeGroupSeparator(out transactionWriter) error {
+	if w, ok := out.(separatorWriter); ok {
+		return w.WriteSeparator()
+	}
+	return nil
+}
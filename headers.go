@@ -0,0 +1,309 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// trimTrailingEmpty drops the empty cells at the end of a row, such as those left by trailing commas
+func trimTrailingEmpty(cells []string) []string {
+	end := len(cells)
+	for end > 0 && strings.TrimSpace(cells[end-1]) == "" {
+		end--
+	}
+	return cells[:end]
+}
+
+// mapRow keys the cells of a data row by header. Trailing empty cells beyond the headers are ignored,
+// and their number returned, while any other extra cells are an error. Missing cells are left empty.
+func mapRow(headers []string, row []string) (map[string]string, int, error) {
+	ignored := 0
+	if len(row) > len(headers) {
+		trimmed := trimTrailingEmpty(row)
+		if len(trimmed) > len(headers) {
+			return nil, 0, fmt.Errorf("row has %d cells but there are only %d headers", len(trimmed), len(headers))
+		}
+		ignored = len(row) - len(headers)
+		row = row[:len(headers)]
+	}
+
+	data := map[string]string{}
+	for i, v := range row {
+		data[headers[i]] = v
+	}
+	return data, ignored, nil
+}
+
+// normalizeHeading collapses the whitespace of a header name, then renames it if it has an alias.
+// Aliases are matched with their whitespace collapsed too, the preset's taking precedence over the defaults.
+// Within a table, aliases are tried in sorted order so the same one wins on every run.
+func normalizeHeading(heading string, aliases map[string]string) string {
+	heading = collapseSpaces(heading)
+	for _, table := range []map[string]string{aliases, defaultHeaderAliases} {
+		var names []string
+		for from := range table {
+			names = append(names, from)
+		}
+		sort.Strings(names)
+		for _, from := range names {
+			if collapseSpaces(from) == heading {
+				return table[from]
+			}
+		}
+	}
+	return heading
+}
+
+// collapseSpaces trims a value and reduces each run of whitespace inside it to a single space
+func collapseSpaces(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// Number of rows shown when the header can't be found
+const headerScanRowsShown = 5
+
+// Most rows searched for the header, no limit when zero
+var maxHeaderScan = 100
+
+// readHeader reads past any preamble up to and including the header row, returning the column names.
+// The error wraps ErrEmptyInput or ErrNoHeader if the header can't be found.
+func readHeader(csvr *csv.Reader, name string, preset *Preset) ([]string, error) {
+	var headers []string
+	rowsScanned := 0
+	// First rows seen, to help tell why no header was found
+	var firstRows []string
+	// Whether the transactions section marker has been passed
+	markerSeen := !preset.SkipToMarker
+	// Read header line
+	for {
+		row, err := csvr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if _, ok := err.(*csv.ParseError); ok {
+				return nil, fmt.Errorf("%w in %s: %s", ErrNoHeader, name, err)
+			}
+			return nil, fmt.Errorf("%w reading %s: %s", ErrIO, name, err)
+		}
+		rowsScanned++
+		if maxHeaderScan > 0 && rowsScanned > maxHeaderScan {
+			return nil, fmt.Errorf("%w in the first %d rows of %s (see -maxheaderscan), which start:%s",
+				ErrNoHeader, maxHeaderScan, name, strings.Join(firstRows, ""))
+		}
+		if len(firstRows) < headerScanRowsShown {
+			firstRows = append(firstRows, fmt.Sprintf("\n  %q", row))
+		}
+		if matchesSignature(row, xeroCSVHeaders[:2]) {
+			xeroLine, _ := csvr.FieldPos(0)
+			if !force {
+				return nil, fmt.Errorf("%s looks like it has already been transformed into the Xero format (header on line %d), use -force to transform it anyway", name, xeroLine)
+			}
+			warnRow(xeroLine, "%s looks like it has already been transformed into the Xero format (header on line %d)", name, xeroLine)
+		}
+		if !markerSeen {
+			if isSectionMarker(row[0], preset.SectionMarkers) {
+				markerLine, _ := csvr.FieldPos(0)
+				log.Debugf("Section marker found on line %d", markerLine)
+				markerSeen = true
+			}
+			continue
+		}
+		// There is extra guff in the export file, so only read the correct header
+		if matchesSignature(row, preset.HeaderSignature) {
+			headerLine, _ := csvr.FieldPos(0)
+			log.Debugf("Header row found on line %d", headerLine)
+			if row, err = joinHeaderRows(csvr, row); err != nil {
+				return nil, fmt.Errorf("%w reading the header of %s: %s", ErrNoHeader, name, err)
+			}
+			headers = headerNames(row, preset, headerLine)
+		}
+		if len(headers) > 0 {
+			break
+		}
+	}
+	if rowsScanned == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrEmptyInput, name)
+	}
+	if !markerSeen {
+		return nil, fmt.Errorf("%w: section marker not found in %s, expected one of: %s", ErrNoHeader, name, strings.Join(preset.SectionMarkers, ", "))
+	}
+	if len(headers) == 0 {
+		return nil, fmt.Errorf("%w in %s, which starts:%s", ErrNoHeader, name, strings.Join(firstRows, ""))
+	}
+	log.Debugf("File headers: %s", headers)
+	return headers, nil
+}
+
+// Physical rows a header spans, its labels split across them as in "Running" over "Balance"
+var headerRows = 1
+
+// joinHeaderRows reads the rest of a header spanning headerRows rows after its first row,
+// joining the labels of each column with a space
+func joinHeaderRows(csvr *csv.Reader, row []string) ([]string, error) {
+	joined := append([]string(nil), row...)
+	for i := 1; i < headerRows; i++ {
+		next, err := csvr.Read()
+		if err == io.EOF {
+			return nil, fmt.Errorf("the header should span %d rows (see -headerrows)", headerRows)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for column, label := range next {
+			if column == len(joined) {
+				joined = append(joined, "")
+			}
+			joined[column] = strings.TrimSpace(joined[column] + " " + label)
+		}
+	}
+	return joined, nil
+}
+
+// listColumns prints the column names of a statement one per line, as mappings refer to them
+func listColumns(w io.Writer, in input, preset *Preset, delimiter rune) error {
+	defer in.reader.Close()
+	headers, err := readHeader(newStatementReader(in.reader, delimiter), in.name, preset)
+	if err != nil {
+		return err
+	}
+	for _, header := range headers {
+		if _, err := fmt.Fprintln(w, header); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// headerNames turns the cells of a header row into column names
+func headerNames(row []string, preset *Preset, line int) []string {
+	if trimmed := trimTrailingEmpty(row); len(trimmed) < len(row) {
+		log.Infof("Ignoring %d trailing empty header cells on line %d", len(row)-len(trimmed), line)
+		row = trimmed
+	}
+	var headers []string
+	for _, heading := range row {
+		headers = append(headers, normalizeHeading(heading, preset.HeaderAliases))
+	}
+	// Empty header cells would all map to the same key, each clobbering the one before
+	var generated []string
+	for i, header := range headers {
+		if header != "" {
+			continue
+		}
+		name := fmt.Sprintf("%s%d", unnamedColumnPrefix, i+1)
+		for containsString(headers, name) {
+			name += "_"
+		}
+		headers[i] = name
+		generated = append(generated, name)
+	}
+	if len(generated) > 0 {
+		log.Noticef("Columns with an empty header on line %d named %s", line, strings.Join(generated, ", "))
+	}
+	return headers
+}
+
+// Start of the names given to columns with an empty header, followed by their position from 1
+var unnamedColumnPrefix = "col"
+
+// sectionHeaders reads a header row found after the first one. Statements holding several accounts
+// start a section with a header of its own, whose columns are used from then on.
+func sectionHeaders(headers []string, row []string, preset *Preset, line int) []string {
+	next := headerNames(row, preset, line)
+	if strings.Join(next, "\x00") == strings.Join(headers, "\x00") {
+		log.Debugf("Repeated header on line %d", line)
+		return headers
+	}
+	log.Noticef("Section with different columns on line %d: %s", line, next)
+	return next
+}
+
+// containsString reports whether a value is among the headers
+func containsString(headers []string, value string) bool {
+	for _, header := range headers {
+		if header == value {
+			return true
+		}
+	}
+	return false
+}
+
+// What to do when columns the mapping reads dates or amounts from are missing from a header
+const (
+	// Stop the run before any row is read
+	missingColumnsFail = "fail"
+	// Warn, leaving the fields read from the columns empty
+	missingColumnsWarn = "warn"
+)
+
+// What happens when columns the mapping needs are missing from a header
+var missingColumnsPolicy = missingColumnsFail
+
+// checkMappedColumns compares a header with the columns the preset maps. Missing date, amount, indicator,
+// direction or currency columns stop the run with an error wrapping ErrMissingColumns, unless the policy is
+// to warn, while missing text columns are only warned about. Amount columns are left to the amount
+// strategies when the preset has some, as they pick among them.
+func checkMappedColumns(headers []string, preset *Preset, name string, line int) error {
+	columns := preset.Columns
+	required := []string{columns.Date, columns.Indicator, columns.Direction, columns.Currency}
+	if len(preset.AmountStrategies) == 0 {
+		required = append(required, columns.Amount, columns.Debit, columns.Credit)
+	}
+	var missing []string
+	for _, column := range required {
+		if column != "" && !containsString(headers, column) {
+			missing = append(missing, column)
+		}
+	}
+	for _, list := range [][]string{columns.Payee, columns.Description, columns.Reference, columns.ChequeNumber} {
+		for _, column := range list {
+			found := false
+			for _, alternative := range strings.Split(column, columnAlternatives) {
+				found = found || containsString(headers, strings.TrimSpace(alternative))
+			}
+			if !found {
+				warnRow(line, "Column %q isn't in the header of %s on line %d, so it's left out of the text", column, name, line)
+			}
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if missingColumnsPolicy == missingColumnsWarn {
+		warnRow(line, "Columns %q aren't in the header of %s on line %d, which has %q", missing, name, line, headers)
+		return nil
+	}
+	return fmt.Errorf("%w: %q aren't in the header of %s on line %d, which has %q (see -missingcolumns)", ErrMissingColumns, missing, name, line, headers)
+}
+
+// What happens when a data row has a different number of cells from its header: "fail", "warn",
+// or nothing when empty, leaving short and long rows to be mapped as well as they can be
+var uniformColumnsPolicy string
+
+// Rows whose number of cells differs from their header
+var unevenRows int
+
+// checkUniformColumns compares the number of cells of a data row with its header, under the
+// -requireuniformcolumns policy. Blank rows are left alone, as are empty cells beyond the header,
+// which trailing commas leave on the header and data rows alike. Only the first uneven row is warned about.
+func checkUniformColumns(headers []string, row []string, name string, line int) error {
+	cells := len(row)
+	if cells > len(headers) && len(trimTrailingEmpty(row)) <= len(headers) {
+		cells = len(headers)
+	}
+	if uniformColumnsPolicy == "" || cells == len(headers) || len(trimTrailingEmpty(row)) == 0 {
+		return nil
+	}
+	unevenRows++
+	if uniformColumnsPolicy == missingColumnsFail {
+		return fmt.Errorf("%w: line %d of %s has %d cells but its header has %d (see -requireuniformcolumns)", ErrBadRow, line, name, cells, len(headers))
+	}
+	if unevenRows == 1 {
+		warnRow(line, "Line %d of %s has %d cells but its header has %d, the first row to differ", line, name, cells, len(headers))
+	}
+	return nil
+}
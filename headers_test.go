@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAlreadyTransformed(t *testing.T) {
+	xeroFile := transformStatement(t, statementHeader+"01/06/2020,CARD,REF1,TESCO,4.01,,1\n", nil)
+
+	_, err := transformStatementErr(xeroFile, nil)
+	if err == nil || !strings.Contains(err.Error(), "already been transformed into the Xero format") {
+		t.Fatalf("got error %v, want the file refused as already transformed", err)
+	}
+
+	// With -force the file is only warned about, though the default preset then finds no header in it
+	setForTest(t, &force, true)
+	_, err = transformStatementErr(xeroFile, nil)
+	if !errors.Is(err, ErrNoHeader) {
+		t.Errorf("got error %v with -force, want %v", err, ErrNoHeader)
+	}
+	if len(summary.Warnings) != 1 || !strings.Contains(summary.Warnings[0].Reason, "Xero format") {
+		t.Errorf("got warnings %+v with -force, want one about the Xero format", summary.Warnings)
+	}
+
+	// A preset reading the Xero columns transforms it again
+	xeroColumns := func(p *Preset) {
+		p.HeaderSignature = []string{"*Date", "*Amount"}
+		p.Columns = ColumnMapping{Date: "*Date", Amount: "*Amount", Description: []string{"Description"}, Reference: []string{"Reference"}}
+	}
+	got := outputRows(transformStatement(t, xeroFile, xeroColumns))
+	if want := outputRows(xeroFile); !reflect.DeepEqual(got, want) {
+		t.Errorf("got rows %q transforming again with -force, want %q", got, want)
+	}
+}
+
+func TestMapRow(t *testing.T) {
+	headers := []string{"Date", "Amount"}
+	tests := []struct {
+		name        string
+		row         []string
+		want        map[string]string
+		wantIgnored int
+		wantErr     bool
+	}{
+		{"exact", []string{"01/06/2020", "1.00"}, map[string]string{"Date": "01/06/2020", "Amount": "1.00"}, 0, false},
+		{"trailing empty cells", []string{"01/06/2020", "1.00", "", " "}, map[string]string{"Date": "01/06/2020", "Amount": "1.00"}, 2, false},
+		{"missing cells", []string{"01/06/2020"}, map[string]string{"Date": "01/06/2020"}, 0, false},
+		{"extra cells", []string{"01/06/2020", "1.00", "", "extra"}, nil, 0, true},
+	}
+	for _, tt := range tests {
+		got, ignored, err := mapRow(headers, tt.row)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: got error %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) || ignored != tt.wantIgnored {
+			t.Errorf("%s: got %v with %d ignored, want %v with %d", tt.name, got, ignored, tt.want, tt.wantIgnored)
+		}
+	}
+}
+
+func TestTrailingCommas(t *testing.T) {
+	statement := "Account Name,Test,,,,,\n" +
+		"Transactions,,,,,,\n" +
+		" Date,Description,Bank Reference,Customer Reference,Debit,Credit,Running Balance,,,\n" +
+		"01/06/2020,CARD,REF1,TESCO,4.01,,1,,,\n" +
+		"02/06/2020,FASTER PAYMENT,REF2,Invoice 1001,,150.00,151,\n"
+	got := outputRows(transformStatement(t, statement, nil))
+	want := []string{
+		"01/06/2020,-4.01,,TESCO,CARD REF1,,Debit",
+		"02/06/2020,150.00,,Invoice 1001,FASTER PAYMENT REF2,,Credit",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got rows %q, want %q", got, want)
+	}
+}
+
+func TestTrimLeadingSpaceHeaderDetection(t *testing.T) {
+	statement := statementHeader +
+		"01/06/2020,  CARD,REF1,   TESCO,4.01,,1\n"
+	tests := []struct {
+		trim bool
+		want string
+	}{
+		{false, `01/06/2020,-4.01,,"   TESCO","  CARD REF1",,Debit`},
+		{true, "01/06/2020,-4.01,,TESCO,CARD REF1,,Debit"},
+	}
+	for _, tt := range tests {
+		setForTest(t, &trimLeadingSpace, tt.trim)
+		// The header row starts " Date", which the signature finds either way
+		got := outputRows(transformStatement(t, statement, nil))
+		if want := []string{tt.want}; !reflect.DeepEqual(got, want) {
+			t.Errorf("trimming %v: got rows %q, want %q", tt.trim, got, want)
+		}
+	}
+}
+
+func TestHeaderNamesUnnamedColumns(t *testing.T) {
+	preset := &Preset{}
+	tests := []struct {
+		row  []string
+		want []string
+	}{
+		{[]string{"", "Date", "Amount"}, []string{"col1", "Date", "Amount"}},
+		{[]string{"Date", " ", "Amount", ""}, []string{"Date", "col2", "Amount"}},
+		{[]string{"", "col1", "", "Amount"}, []string{"col1_", "col1", "col3", "Amount"}},
+		{[]string{"Date", "Amount"}, []string{"Date", "Amount"}},
+	}
+	for _, tt := range tests {
+		if got := headerNames(tt.row, preset, 1); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.row, got, tt.want)
+		}
+	}
+}
+
+func TestUnnamedLeadingColumn(t *testing.T) {
+	statement := ",Date,Memo,,Amount\n" +
+		"1,01/06/2020,CARD,ignored,-4.01\n" +
+		"2,02/06/2020,TRANSFER,,150.00\n"
+	got := outputRows(transformStatement(t, statement, func(p *Preset) {
+		p.HeaderSignature = []string{"", "Date"}
+		p.Columns = ColumnMapping{Date: "Date", Amount: "Amount", Description: []string{"Memo"}, Reference: []string{"col1"}}
+	}))
+	want := []string{"01/06/2020,-4.01,,CARD,1,,Debit", "02/06/2020,150.00,,TRANSFER,2,,Credit"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got rows %q, want %q", got, want)
+	}
+}
+
+func TestTwoRowHeader(t *testing.T) {
+	setForTest(t, &headerRows, 2)
+	header := "Transactions,,,,,,\n" +
+		" Date,Description,Bank,Customer,Debit,Credit,Running\n" +
+		",,Reference,Reference,,,Balance\n"
+	tests := []struct {
+		name      string
+		statement string
+		want      []string
+		wantErr   bool
+	}{
+		{"joined labels", header + "01/06/2020,CARD,REF1,TESCO,4.01,,1\n",
+			[]string{"01/06/2020,-4.01,,TESCO,CARD REF1,,Debit"}, false},
+		{"second row longer", "Transactions\n Date,Description,Bank,Customer,Debit\n,,Reference,Reference,,Credit\n" +
+			"01/06/2020,PAY,REF2,INV,,150.00\n",
+			[]string{"01/06/2020,150.00,,INV,PAY REF2,,Credit"}, false},
+		{"missing second row", "Transactions\n Date,Description,Bank Reference,Customer Reference,Debit,Credit\n", nil, true},
+	}
+	for _, tt := range tests {
+		output, err := transformStatementErr(tt.statement, nil)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: got error %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got := outputRows(output); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got rows %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUniformColumnsTrailingCommas(t *testing.T) {
+	setForTest(t, &uniformColumnsPolicy, missingColumnsFail)
+	header := "Transactions,,,,,,\n" +
+		" Date,Description,Bank Reference,Customer Reference,Debit,Credit,Running Balance,\n"
+	tests := []struct {
+		name    string
+		rows    string
+		want    int
+		wantErr bool
+	}{
+		{"every line ends with a comma", "01/06/2020,CARD,REF1,TESCO,4.01,,1,\n02/06/2020,PAY,REF2,INV,,150.00,,\n", 2, false},
+		{"extra empty cells", "01/06/2020,CARD,REF1,TESCO,4.01,,1,,,\n", 1, false},
+		{"without the trailing comma", "01/06/2020,CARD,REF1,TESCO,4.01,,1\n", 1, false},
+		{"a short row", "01/06/2020,CARD,REF1,TESCO,4.01\n", 0, true},
+		{"an extra value", "01/06/2020,CARD,REF1,TESCO,4.01,,1,extra\n", 0, true},
+	}
+	for _, tt := range tests {
+		setForTest(t, &unevenRows, 0)
+		output, err := transformStatementErr(header+tt.rows, nil)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: got error %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			if !errors.Is(err, ErrBadRow) {
+				t.Errorf("%s: got error %v, want %v", tt.name, err, ErrBadRow)
+			}
+			continue
+		}
+		if got := outputRows(output); len(got) != tt.want {
+			t.Errorf("%s: got rows %q, want %d", tt.name, got, tt.want)
+		}
+	}
+}
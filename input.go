@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/yeka/zip"
+)
+
+// input is a statement to read transactions from
+type input struct {
+	name   string
+	reader io.ReadCloser
+}
+
+// openInputs opens the statements to transform. A ZIP archive yields every CSV file it contains,
+// as does a directory with -recurse, and an http or https URL is downloaded.
+func openInputs(filePath string) ([]input, error) {
+	if filePath == "-" {
+		return []input{{name: stdinName, reader: ioutil.NopCloser(os.Stdin)}}, nil
+	}
+	if isURL(filePath) {
+		body, err := fetchURL(filePath)
+		if err != nil {
+			return nil, err
+		}
+		return []input{{name: filePath, reader: body}}, nil
+	}
+	if info, err := os.Stat(filePath); err == nil && info.IsDir() {
+		if latest != "" {
+			return openLatestInput(filePath)
+		}
+		if !recurse {
+			return nil, fmt.Errorf("%s is a directory, use -recurse to transform every CSV file in it, or -latest for the newest", filePath)
+		}
+		return openDirInputs(filePath)
+	}
+	if strings.EqualFold(filepath.Ext(filePath), ".zip") {
+		return openZipInputs(filePath)
+	}
+	return []input{{name: filePath, reader: openFile(filePath)}}, nil
+}
+
+// Name of the statement read from standard input
+const stdinName = "stdin"
+
+// sourceName shortens an input name to the base name of its file
+func sourceName(name string) string {
+	if isURL(name) {
+		if u, err := url.Parse(name); err == nil {
+			return path.Base(u.Path)
+		}
+	}
+	return filepath.Base(name)
+}
+
+// isURL reports whether an input is to be downloaded rather than read from a file
+func isURL(filePath string) bool {
+	lower := strings.ToLower(filePath)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// fetchURL starts downloading a statement, returning its body to stream through the transform
+func fetchURL(address string) (io.ReadCloser, error) {
+	request, err := http.NewRequest(http.MethodGet, address, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, header := range fetchHeaders {
+		parts := strings.SplitN(header, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid -header %q, expected Name: value", header)
+		}
+		request.Header.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+	}
+
+	client := &http.Client{Timeout: fetchTimeout}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("%w downloading statement: %s", ErrIO, err)
+	}
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, fmt.Errorf("%w downloading statement: server answered %s", ErrIO, response.Status)
+	}
+	log.Debugf("Downloading statement, %s", response.Header.Get("Content-Type"))
+	return response.Body, nil
+}
+
+// openDirInputs opens the CSV files under a directory and its subdirectories, in name order
+func openDirInputs(dir string) ([]input, error) {
+	var inputs []input
+	err := filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.EqualFold(filepath.Ext(filePath), ".csv") {
+			return nil
+		}
+		reader, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		inputs = append(inputs, input{name: filePath, reader: reader})
+		return nil
+	})
+	if err != nil {
+		for _, in := range inputs {
+			in.reader.Close()
+		}
+		return nil, err
+	}
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("no CSV files found in %s", dir)
+	}
+	log.Debugf("Found %d CSV files in %s", len(inputs), dir)
+	return inputs, nil
+}
+
+// Ways of picking the newest statement in a directory given as -file
+const (
+	// The most recently modified CSV file
+	latestModified = "modified"
+	// The CSV file with the latest date in its name, such as statement-2024-03-31.csv
+	latestNamed = "named"
+)
+
+// How the statement to transform is picked from a directory, every CSV file being transformed when empty
+var latest string
+
+// Date in a file name, with or without dashes
+var fileNameDate = regexp.MustCompile(`(\d{4})-?(\d{2})-?(\d{2})`)
+
+// openLatestInput opens the newest CSV file in a directory, or under it with -recurse, as picked by -latest
+func openLatestInput(dir string) ([]input, error) {
+	var chosen string
+	var newest time.Time
+	err := filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if filePath != dir && !recurse {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.EqualFold(filepath.Ext(filePath), ".csv") {
+			return nil
+		}
+		stamp := info.ModTime()
+		if latest == latestNamed {
+			var ok bool
+			if stamp, ok = nameDate(info.Name()); !ok {
+				log.Debugf("Passing over %s as its name holds no date", filePath)
+				return nil
+			}
+		}
+		// Files are walked in name order, so the last of those with the same time wins
+		if chosen == "" || !stamp.Before(newest) {
+			chosen, newest = filePath, stamp
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if chosen == "" {
+		if latest == latestNamed {
+			return nil, fmt.Errorf("no CSV files with a date in their name found in %s", dir)
+		}
+		return nil, fmt.Errorf("no CSV files found in %s", dir)
+	}
+	if latest == latestNamed {
+		log.Noticef("Transforming %s, the CSV file in %s with the latest date in its name, %s", chosen, dir, newest.Format("2 January 2006"))
+	} else {
+		log.Noticef("Transforming %s, the CSV file in %s modified last, at %s", chosen, dir, newest.Format("2 January 2006 15:04"))
+	}
+	return []input{{name: chosen, reader: openFile(chosen)}}, nil
+}
+
+// nameDate finds the date in a file name, taking the last one when there are several
+func nameDate(name string) (time.Time, bool) {
+	matches := fileNameDate.FindAllStringSubmatch(name, -1)
+	for i := len(matches) - 1; i >= 0; i-- {
+		m := matches[i]
+		if date, err := time.Parse("20060102", m[1]+m[2]+m[3]); err == nil {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// openZipInputs extracts the CSV members of a (possibly password protected) ZIP archive
+func openZipInputs(filePath string) ([]input, error) {
+	archive, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	var inputs []input
+	for _, member := range archive.File {
+		if member.FileInfo().IsDir() || !strings.EqualFold(path.Ext(member.Name), ".csv") {
+			continue
+		}
+		if member.IsEncrypted() {
+			if zipPassword == "" {
+				return nil, fmt.Errorf("%s in %s is password protected, use -zippassword", member.Name, filePath)
+			}
+			member.SetPassword(zipPassword)
+		}
+
+		content, err := readZipMember(member)
+		if err != nil {
+			return nil, fmt.Errorf("unable to extract %s from %s: %s", member.Name, filePath, err)
+		}
+		log.Debugf("Extracted %s from %s", member.Name, filePath)
+		inputs = append(inputs, input{
+			name:   filePath + ":" + member.Name,
+			reader: ioutil.NopCloser(bytes.NewReader(content)),
+		})
+	}
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("no CSV files found in %s", filePath)
+	}
+
+	return inputs, nil
+}
+
+// readZipMember reads the whole of an archive member
+func readZipMember(member *zip.File) ([]byte, error) {
+	rc, err := member.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOpenInputsDirectory(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.csv", "a.CSV", "notes.txt", "sub/c.csv"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	empty := t.TempDir()
+
+	tests := []struct {
+		name      string
+		dir       string
+		recurse   bool
+		wantNames []string
+		wantErr   string
+	}{
+		{"a directory needs -recurse", dir, false, nil, "is a directory, use -recurse"},
+		{"every CSV file under the directory", dir, true, []string{"a.CSV", "b.csv", "sub/c.csv"}, ""},
+		{"no CSV files", empty, true, nil, "no CSV files found"},
+	}
+	for _, tt := range tests {
+		setForTest(t, &recurse, tt.recurse)
+		inputs, err := openInputs(tt.dir)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: got error %v, want one saying %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		var names []string
+		for _, in := range inputs {
+			rel, _ := filepath.Rel(tt.dir, in.name)
+			names = append(names, filepath.ToSlash(rel))
+			in.reader.Close()
+		}
+		if !reflect.DeepEqual(names, tt.wantNames) {
+			t.Errorf("%s: got inputs %q, want %q", tt.name, names, tt.wantNames)
+		}
+	}
+}
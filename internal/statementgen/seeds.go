@@ -0,0 +1,66 @@
+package statementgen
+
+// Seed is an awkward statement as exported by real banks, for seeding fuzzing of the transform
+type Seed struct {
+	Name string
+	CSV  []byte
+}
+
+// Amounts are awkward amount cells met in real exports, for seeding fuzzing of amount parsing.
+// Not all of them are valid.
+var Amounts = []string{
+	"0.00",
+	"1,234.56",
+	"(25.99)",
+	"25.99-",
+	"25.99 CR",
+	"25.99DR",
+	"£1,000.00",
+	"-€3.50",
+	"$ 12",
+	"1.005",
+	"1.234,56",
+	" ",
+	"--1",
+	"()",
+	"1e3",
+}
+
+// Dates are awkward date cells met in real exports, for seeding fuzzing of date parsing.
+// Not all of them are valid.
+var Dates = []string{
+	"01/06/2020",
+	"1/6/20",
+	"2020-06-01",
+	"1st June 2020",
+	"June 1, 2020",
+	"31/02/2020",
+	"1591000000",
+	"1591000000000",
+	"00/00/0000",
+	"",
+}
+
+// Seeds returns awkward statements: generated ones with each quirk, and hand written ones with
+// ragged rows, parenthesised amounts and broken quoting
+func Seeds() []Seed {
+	seeds := []Seed{
+		{"plain", Generate(1, 5, Quirks{DebitRatio: 0.5}).CSV},
+		{"bom", Generate(2, 5, Quirks{BOM: true, DebitRatio: 0.5}).CSV},
+		{"spacing", Generate(3, 5, Quirks{OddSpacing: true, DebitRatio: 0.5}).CSV},
+		{"repeated header", Generate(4, 9, Quirks{RepeatHeaderEvery: 3, DebitRatio: 0.5}).CSV},
+		{"empty", nil},
+	}
+	for _, s := range []struct{ name, csv string }{
+		{"ragged rows", "Account Name,Ragged\nTransactions\n Date,Description,Bank Reference,Customer Reference,Debit,Credit,Running Balance\n" +
+			"01/06/2020,FASTER PAYMENT,REF1,Invoice 1001,,150.00\n02/06/2020,CARD PAYMENT\n03/06/2020,CARD PAYMENT,REF3,TESCO,4.01,,1120.00,extra,cells\n"},
+		{"parenthesised amounts", "Transactions\n Date,Description,Bank Reference,Customer Reference,Debit,Credit,Running Balance\n" +
+			"01/06/2020,FASTER PAYMENT,REF1,Invoice 1001,,\"1,150.00\",1150.00\n02/06/2020,CARD PAYMENT,REF2,AMAZON UK,(25.99),,(1124.01)\n"},
+		{"broken quoting", "Transactions\n Date,Description,Bank Reference,Customer Reference,Debit,Credit,Running Balance\n" +
+			"01/06/2020,\"FASTER \"PAYMENT,REF1,Invoice 1001,,150.00,1150.00\n02/06/2020,\"CARD PAYMENT,REF2,AMAZON UK,25.99,,1124.01\n"},
+		{"no header", "01/06/2020,FASTER PAYMENT,REF1,Invoice 1001,,150.00,1150.00\n"},
+	} {
+		seeds = append(seeds, Seed{s.name, []byte(s.csv)})
+	}
+	return seeds
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Strategies for dealing with problem rows
+const (
+	// Stop at the first problem row
+	strategyFailFast = "failfast"
+	// Reject problem rows, carry on and report them all at the end
+	strategyCollect = "collect"
+)
+
+// Issue is a problem found with a single source row
+type Issue struct {
+	// Input the row was read from
+	File   string
+	Line   int
+	Reason string
+	Row    []string
+}
+
+// currentInput names the input being read, for the issues found in it
+func currentInput() string {
+	if len(summary.Inputs) == 0 {
+		return ""
+	}
+	return summary.Inputs[len(summary.Inputs)-1]
+}
+
+var (
+	// Writer for rejected rows, nil when no reject file is wanted
+	rejectWriter *csv.Writer
+	// Whether the reject file header has been written, as it's only wanted once for several inputs
+	rejectHeaderWritten bool
+	// Source columns named in the reject file header, which every rejected row is padded to
+	rejectColumns int
+	// Print rejected rows to stderr as they happen, up to maxRejectsShown of them
+	showRejects     bool
+	maxRejectsShown int
+	rejectsShown    int
+)
+
+// validateErrorStrategy checks the -errorstrategy value
+func validateErrorStrategy(strategy string) error {
+	switch strategy {
+	case strategyFailFast, strategyCollect:
+		return nil
+	}
+	return fmt.Errorf("unknown error strategy %q, expected %s or %s", strategy, strategyFailFast, strategyCollect)
+}
+
+// writeRejectHeader writes the header of the reject file, followed by the source column names
+func writeRejectHeader(headers []string) {
+	if rejectWriter == nil || rejectHeaderWritten {
+		return
+	}
+	rejectHeaderWritten = true
+	rejectColumns = len(headers)
+	writeReject(append([]string{"Line", "Reason"}, headers...))
+}
+
+// writeReject writes a row of the reject file. The csv writer quotes any cell holding a delimiter,
+// quote or newline, so the file can always be read back in.
+func writeReject(record []string) {
+	rejectWriter.Write(record)
+	rejectWriter.Flush()
+	if err := rejectWriter.Error(); err != nil {
+		log.Errorf("Unable to write to the reject file: %s", err)
+	}
+}
+
+// rejectRow records a source row that could not be transformed.
+// With the failfast strategy it returns an error wrapping ErrBadRow to stop the run.
+func rejectRow(line int, row []string, reason string) error {
+	if explaining(line) {
+		explainf(line, "Rejected: %s", reason)
+		return errExplained
+	}
+	if errorStrategy == strategyFailFast {
+		return fmt.Errorf("%w: line %d: %s", ErrBadRow, line, reason)
+	}
+
+	log.Errorf("Rejected line %d: %s", line, reason)
+	summary.Rejected++
+	summary.Issues = append(summary.Issues, Issue{File: currentInput(), Line: line, Reason: reason, Row: row})
+
+	if rejectWriter != nil {
+		record := append([]string{strconv.Itoa(line), reason}, row...)
+		// Rows cut short by a quoting error are padded so every record has the header's width
+		for len(record) < rejectColumns+2 {
+			record = append(record, "")
+		}
+		writeReject(record)
+	}
+	if showRejects {
+		showReject(line, row, reason)
+	}
+	return nil
+}
+
+// showReject prints a rejected row on stderr, saying once when no more will be shown
+func showReject(line int, row []string, reason string) {
+	rejectsShown++
+	switch {
+	case maxRejectsShown > 0 && rejectsShown == maxRejectsShown+1:
+		fmt.Fprintf(os.Stderr, "reject: more rows rejected, see -rejectfile or the log\n")
+	case maxRejectsShown <= 0 || rejectsShown <= maxRejectsShown:
+		fmt.Fprintf(os.Stderr, "reject: line %d: %s: %q\n", line, reason, row)
+	}
+}
+
+// warnRow logs a warning about a source row and keeps it for the run summary
+func warnRow(line int, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	log.Warning(message)
+	summary.Warnings = append(summary.Warnings, Issue{File: currentInput(), Line: line, Reason: message})
+}
+
+// reportIssues logs every problem collected during the run
+func reportIssues() {
+	if len(summary.Issues) == 0 {
+		return
+	}
+	log.Warningf("%d rows were rejected:", len(summary.Issues))
+	for _, issue := range summary.Issues {
+		log.Warningf("  line %d: %s", issue.Line, issue.Reason)
+	}
+}
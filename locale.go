@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// localeNumberFormat derives the digit grouping and decimal separators of a locale such as "de-DE"
+// by looking at how the locale prints a sample number
+func localeNumberFormat(locale string) (numberFormat, error) {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return numberFormat{}, fmt.Errorf("invalid locale %q: %s", locale, err)
+	}
+
+	sample := message.NewPrinter(tag).Sprintf("%.2f", 1234567.89)
+	groupEnd := strings.Index(sample, "234")
+	fractionStart := strings.LastIndex(sample, "89")
+	if !strings.HasPrefix(sample, "1") || groupEnd < 0 || fractionStart < groupEnd {
+		return numberFormat{}, fmt.Errorf("unsupported locale %q, amounts are written as %q", locale, sample)
+	}
+
+	decimal, _ := utf8.DecodeLastRuneInString(sample[:fractionStart])
+	return numberFormat{
+		thousands: sample[1:groupEnd],
+		decimal:   string(decimal),
+	}, nil
+}
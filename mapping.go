@@ -0,0 +1,268 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// transaction is a Xero output row along with what was learnt about it from the source
+type transaction struct {
+	*Transform
+	// Source lines the transaction was built from
+	lines []int
+	// Transaction date, zero when it couldn't be parsed
+	date time.Time
+	// Signed amount in pence, only meaningful when hasAmount is set
+	amount    int64
+	hasAmount bool
+	// Amount and currency before conversion into the base currency, empty when not converted
+	originalAmount   string
+	originalCurrency string
+	// Position of the transaction among those read, counting from 1
+	index int
+	// Amount strategy the amount was read with, empty when the preset lists none
+	amountStrategy string
+	// Base name of the statement the transaction was read from
+	source string
+	// Debit and credit cells as found in the source
+	rawDebit  string
+	rawCredit string
+}
+
+// setAmount gives the transaction a signed amount in pence
+func (t *transaction) setAmount(amount int64) {
+	t.amount = amount
+	t.hasAmount = true
+	t.Amount = formatAmount(amount)
+}
+
+// transformFieldRef returns the named Transform field, nil for an unknown name
+func transformFieldRef(t *Transform, name string) *string {
+	switch strings.ToLower(strings.Replace(name, " ", "", -1)) {
+	case "date":
+		return &t.Date
+	case "amount":
+		return &t.Amount
+	case "payee":
+		return &t.Payee
+	case "description":
+		return &t.Description
+	case "reference":
+		return &t.Reference
+	case "chequenumber":
+		return &t.ChequeNumber
+	case "transactiontype":
+		return &t.TransactionType
+	}
+	return nil
+}
+
+// transformField returns the value of the named Transform field
+func transformField(t *Transform, name string) (string, error) {
+	field := transformFieldRef(t, name)
+	if field == nil {
+		return "", fmt.Errorf("unknown field %q", name)
+	}
+	return *field, nil
+}
+
+// hasValue reports whether a source cell holds a value. Cells of only whitespace, or "<nil>", are empty.
+func hasValue(value string) bool {
+	value = strings.TrimSpace(value)
+	return value != "" && value != "<nil>"
+}
+
+// joinColumns joins the values of the given source columns with a space, leaving out empty ones
+// so no field is made of separators alone
+func joinColumns(data map[string]string, columns []string) string {
+	var values []string
+	for _, column := range columns {
+		if value := columnValue(data, column); hasValue(value) {
+			values = append(values, value)
+		}
+	}
+	return strings.Join(values, " ")
+}
+
+// blankEmptyFields empties the text fields left holding only whitespace, as by a row script or continuation
+func blankEmptyFields(t *Transform) {
+	for _, field := range []*string{&t.Payee, &t.Description, &t.Reference, &t.ChequeNumber} {
+		if strings.TrimSpace(*field) == "" {
+			*field = ""
+		}
+	}
+}
+
+// Separates alternative source columns of a text field
+const columnAlternatives = "|"
+
+// columnValue returns the value of a source column, or of the first of its alternatives holding a value
+func columnValue(data map[string]string, column string) string {
+	alternatives := strings.Split(column, columnAlternatives)
+	if len(alternatives) == 1 {
+		return data[column]
+	}
+	for _, alternative := range alternatives {
+		if value := data[strings.TrimSpace(alternative)]; hasValue(value) {
+			return value
+		}
+	}
+	return ""
+}
+
+// buildTransform maps a source row onto a Xero transaction using the preset's columns
+func buildTransform(data map[string]string, preset *Preset, line int) (*transaction, error) {
+	columns := preset.Columns
+	xeroTransaction := &Transform{
+		Date:         data[columns.Date],
+		Payee:        joinColumns(data, columns.Payee),
+		Description:  joinColumns(data, columns.Description),
+		Reference:    joinColumns(data, columns.Reference),
+		ChequeNumber: joinColumns(data, columns.ChequeNumber),
+	}
+
+	t := &transaction{Transform: xeroTransaction, lines: []int{line}}
+	if explaining(line) {
+		explainSources(line, "Payee", data, columns.Payee)
+		explainSources(line, "Description", data, columns.Description)
+		explainSources(line, "Reference", data, columns.Reference)
+		explainSources(line, "ChequeNumber", data, columns.ChequeNumber)
+	}
+
+	date, err := parseDate(xeroTransaction.Date, preset.DateFormat)
+	if errors.Is(err, errNotTimestamp) {
+		return nil, err
+	}
+	if err == nil {
+		t.date = date
+		explainf(line, "Date %s=%q read as %s", columns.Date, xeroTransaction.Date, date.Format("2 January 2006"))
+	} else {
+		explainf(line, "Date %s=%q couldn't be read: %s", columns.Date, xeroTransaction.Date, err)
+	}
+	// Dates are only rewritten when the preset says how to read them
+	if preset.DateFormat != "" {
+		if err != nil {
+			warnRow(line, "Unable to parse date %q on line %d, leaving it unchanged", xeroTransaction.Date, line)
+		} else {
+			xeroTransaction.Date = formatDate(date, outputDateFormat)
+			explainf(line, "Date written as %q with the layout %s", xeroTransaction.Date, outputDateFormat)
+		}
+	} else {
+		explainf(line, "Date written unchanged, as there is no -dateformat")
+	}
+
+	if len(preset.AmountStrategies) > 0 {
+		if err := readAmountStrategies(t, data, preset); err != nil {
+			return nil, err
+		}
+		if t.hasAmount {
+			explainf(line, "Amount %q read as %s with the %s strategy", t.rawDebit+t.rawCredit, t.Amount, t.amountStrategy)
+		} else {
+			explainf(line, "No amount found with the strategies %s", strings.Join(preset.AmountStrategies, ", "))
+		}
+		return t, nil
+	}
+
+	if columns.Amount != "" && hasValue(data[columns.Amount]) {
+		read := signedAmount
+		if columns.Indicator != "" {
+			// The amount is unsigned, with its direction given by the indicator column
+			read = indicatorAmount
+		} else if columns.Direction != "" {
+			// The amount is unsigned, with a yes/no column saying whether it's a debit
+			read = directionAmount
+		}
+		amount, column, _, err := read(data, preset)
+		if err != nil {
+			return nil, err
+		}
+		setAmountFrom(t, amount, data[column])
+		explainf(line, "Amount %s=%q read as %s", column, data[column], t.Amount)
+		if columns.Indicator != "" {
+			explainf(line, "Amount signed by the indicator %s=%q", columns.Indicator, data[columns.Indicator])
+		} else if columns.Direction != "" {
+			explainf(line, "Amount signed by the direction %s=%q", columns.Direction, data[columns.Direction])
+		}
+	}
+	// Separate credit and debit columns hold amounts without a sign
+	if columns.Credit != "" && hasValue(data[columns.Credit]) {
+		amount, err := parseAmount(data[columns.Credit])
+		if err != nil {
+			return nil, err
+		}
+		t.setAmount(abs(amount))
+		xeroTransaction.TransactionType = creditType
+		t.rawCredit = data[columns.Credit]
+		explainf(line, "Amount %s=%q read as the credit %s", columns.Credit, data[columns.Credit], t.Amount)
+	}
+	if columns.Debit != "" && hasValue(data[columns.Debit]) {
+		amount, err := parseAmount(data[columns.Debit])
+		if err != nil {
+			return nil, err
+		}
+		t.setAmount(-abs(amount))
+		xeroTransaction.TransactionType = debitType
+		t.rawDebit = data[columns.Debit]
+		explainf(line, "Amount %s=%q read as the debit %s", columns.Debit, data[columns.Debit], t.Amount)
+	}
+
+	return t, nil
+}
+
+// continuationText returns the text of a row continuing the description of the row before it,
+// which has neither a date nor an amount
+func continuationText(headers []string, row []string, preset *Preset) (string, bool) {
+	data, _, err := mapRow(headers, row)
+	if err != nil || hasValue(data[preset.Columns.Date]) {
+		return "", false
+	}
+	for _, column := range []string{preset.Columns.Amount, preset.Columns.Debit, preset.Columns.Credit} {
+		if column != "" && hasValue(data[column]) {
+			return "", false
+		}
+	}
+	var text []string
+	for _, cell := range row {
+		if cell = strings.TrimSpace(cell); hasValue(cell) {
+			text = append(text, cell)
+		}
+	}
+	if len(text) == 0 {
+		return "", false
+	}
+	return strings.Join(text, " "), true
+}
+
+// Indicator values used when the preset doesn't give any
+var (
+	defaultDebitIndicators  = []string{"D", "DR", "Debit"}
+	defaultCreditIndicators = []string{"C", "CR", "Credit"}
+	// Direction values used when the preset doesn't give any, for a column saying whether it's a debit
+	defaultDirectionDebit  = []string{"Y", "Yes", "true", "1"}
+	defaultDirectionCredit = []string{"N", "No", "false", "0"}
+)
+
+// matchesAny reports whether a value is one of the tokens, ignoring case and surrounding spaces.
+// The fallback tokens are used when no tokens are given.
+func matchesAny(value string, tokens []string, fallback []string) bool {
+	if len(tokens) == 0 {
+		tokens = fallback
+	}
+	value = strings.TrimSpace(value)
+	for _, token := range tokens {
+		if strings.EqualFold(value, strings.TrimSpace(token)) {
+			return true
+		}
+	}
+	return false
+}
+
+// abs returns the magnitude of an amount
+func abs(amount int64) int64 {
+	if amount < 0 {
+		return -amount
+	}
+	return amount
+}
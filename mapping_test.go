@@ -0,0 +1,282 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestJoinContinuations(t *testing.T) {
+	setForTest(t, &joinContinuations, true)
+	tests := []struct {
+		name      string
+		statement string
+		want      []string
+		skipped   int
+	}{
+		{
+			name: "joined to the reference",
+			statement: statementHeader +
+				"01/06/2020,CARD PAYMENT,REF1,TESCO,4.01,,1120.00\n" +
+				",STORE 123,,,,,\n" +
+				",LONDON,,,,,\n" +
+				"02/06/2020,CARD PAYMENT,REF2,AMAZON,1.00,,1119.00\n",
+			want: []string{
+				"01/06/2020,-4.01,,TESCO,CARD PAYMENT REF1 STORE 123 LONDON,,Debit",
+				"02/06/2020,-1.00,,AMAZON,CARD PAYMENT REF2,,Debit",
+			},
+		},
+		{
+			name: "continuation of the last transaction",
+			statement: statementHeader +
+				"01/06/2020,CARD PAYMENT,REF1,TESCO,4.01,,1120.00\n" +
+				",STORE 123,,,,,\n",
+			want: []string{"01/06/2020,-4.01,,TESCO,CARD PAYMENT REF1 STORE 123,,Debit"},
+		},
+		{
+			name: "nothing to join to",
+			statement: statementHeader +
+				",STORE 123,,,,,\n" +
+				"01/06/2020,CARD PAYMENT,REF1,TESCO,4.01,,1120.00\n",
+			want:    []string{"01/06/2020,-4.01,,TESCO,CARD PAYMENT REF1,,Debit"},
+			skipped: 1,
+		},
+		{
+			name: "rows with an amount aren't continuations",
+			statement: statementHeader +
+				"01/06/2020,CARD PAYMENT,REF1,TESCO,4.01,,1120.00\n" +
+				",REFUND,,,,1.00,\n",
+			want:    []string{"01/06/2020,-4.01,,TESCO,CARD PAYMENT REF1,,Debit"},
+			skipped: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := outputRows(transformStatement(t, tt.statement, nil))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got rows %q, want %q", got, tt.want)
+			}
+			if summary.Skipped != tt.skipped {
+				t.Errorf("got %d rows skipped, want %d", summary.Skipped, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestJoinContinuationsRedacted(t *testing.T) {
+	setForTest(t, &joinContinuations, true)
+	r, err := newRedactor([]string{"Description"}, redactMask)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setForTest(t, &redaction, r)
+	statement := statementHeader +
+		"01/06/2020,ACME,BR1,X,,1.00,1.00\n" +
+		",SECRETACCT 9999,,,,,\n"
+	got := outputRows(transformStatement(t, statement, nil))
+	want := []string{"01/06/2020,1.00,,X,**** BR1 ********** ****,,Credit"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got rows %q, want %q", got, want)
+	}
+}
+
+func TestIndicatorAmounts(t *testing.T) {
+	tests := []struct {
+		name      string
+		amount    string
+		indicator string
+		debits    []string
+		credits   []string
+		want      string
+	}{
+		{"default debit", "25.99", "D", nil, nil, "-25.99,,,,,Debit"},
+		{"default debit any case", "25.99", " dr ", nil, nil, "-25.99,,,,,Debit"},
+		{"default debit word", "25.99", "Debit", nil, nil, "-25.99,,,,,Debit"},
+		{"default credit", "25.99", "C", nil, nil, "25.99,,,,,Credit"},
+		{"default credit any case", "25.99", "cr", nil, nil, "25.99,,,,,Credit"},
+		{"signed credit is unsigned", "-25.99", "Credit", nil, nil, "25.99,,,,,Credit"},
+		{"signed debit stays a debit", "-25.99", "D", nil, nil, "-25.99,,,,,Debit"},
+		{"configured debit", "25.99", "out", []string{"OUT"}, []string{"IN"}, "-25.99,,,,,Debit"},
+		{"configured credit", "25.99", "IN", []string{"OUT"}, []string{"IN"}, "25.99,,,,,Credit"},
+		{"configured replaces the defaults", "25.99", "D", []string{"OUT"}, []string{"IN"}, ""},
+		{"unknown indicator", "25.99", "X", nil, nil, ""},
+	}
+	for _, tt := range tests {
+		statement := "Date,Amount,D/C\n01/06/2020," + tt.amount + "," + tt.indicator + "\n"
+		output, err := transformStatementErr(statement, func(p *Preset) {
+			p.HeaderSignature = []string{"Date", "Amount"}
+			p.Columns = ColumnMapping{Date: "Date", Amount: "Amount", Indicator: "D/C"}
+			p.DebitIndicators = tt.debits
+			p.CreditIndicators = tt.credits
+		})
+		if tt.want == "" {
+			// Rows with an unknown indicator are bad rows, failing the run by default
+			if !errors.Is(err, ErrBadRow) {
+				t.Errorf("%s: got error %v, want %v", tt.name, err, ErrBadRow)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if got, want := outputRows(output), []string{"01/06/2020," + tt.want}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got rows %q, want %q", tt.name, got, want)
+		}
+	}
+}
+
+func TestDirectionAmounts(t *testing.T) {
+	tests := []struct {
+		name      string
+		direction string
+		debits    []string
+		credits   []string
+		want      string
+	}{
+		{"Y is a debit", "Y", nil, nil, "-25.99,,,,,Debit"},
+		{"N is a credit", "N", nil, nil, "25.99,,,,,Credit"},
+		{"yes any case", "yes", nil, nil, "-25.99,,,,,Debit"},
+		{"true is a debit", "true", nil, nil, "-25.99,,,,,Debit"},
+		{"false is a credit", " FALSE ", nil, nil, "25.99,,,,,Credit"},
+		{"1 is a debit", "1", nil, nil, "-25.99,,,,,Debit"},
+		{"configured debit", "OUT", []string{"out"}, []string{"in"}, "-25.99,,,,,Debit"},
+		{"configured credit", "in", []string{"out"}, []string{"in"}, "25.99,,,,,Credit"},
+		{"configured replaces the defaults", "Y", []string{"out"}, []string{"in"}, ""},
+		{"unknown direction", "maybe", nil, nil, ""},
+	}
+	for _, tt := range tests {
+		statement := "Date,Amount,IsDebit\n01/06/2020,25.99," + tt.direction + "\n"
+		output, err := transformStatementErr(statement, func(p *Preset) {
+			p.HeaderSignature = []string{"Date", "Amount"}
+			p.Columns = ColumnMapping{Date: "Date", Amount: "Amount", Direction: "IsDebit"}
+			p.DirectionDebit = tt.debits
+			p.DirectionCredit = tt.credits
+		})
+		if tt.want == "" {
+			if !errors.Is(err, ErrBadRow) {
+				t.Errorf("%s: got error %v, want %v", tt.name, err, ErrBadRow)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if got, want := outputRows(output), []string{"01/06/2020," + tt.want}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got rows %q, want %q", tt.name, got, want)
+		}
+	}
+}
+
+func TestColumnValueAlternatives(t *testing.T) {
+	data := map[string]string{"Customer Reference": "INV 1001", "Bank Reference": "BR1", "Blank": "  "}
+	tests := []struct {
+		column string
+		want   string
+	}{
+		{"Customer Reference", "INV 1001"},
+		{"Customer Reference|Bank Reference", "INV 1001"},
+		{"Bank Reference | Customer Reference", "BR1"},
+		{"Blank|Bank Reference", "BR1"},
+		{"Missing|Customer Reference", "INV 1001"},
+		{"Missing|Blank", ""},
+	}
+	for _, tt := range tests {
+		if got := columnValue(data, tt.column); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.column, got, tt.want)
+		}
+	}
+}
+
+func TestPreferNonEmptyReference(t *testing.T) {
+	statement := statementHeader +
+		"01/06/2020,CARD,BR1,INV 1001,4.01,,1\n" +
+		"02/06/2020,CARD,BR2,,4.01,,1\n"
+	got := outputRows(transformStatement(t, statement, func(p *Preset) {
+		p.Columns.Description = []string{"Description"}
+		p.Columns.Reference = []string{"Customer Reference|Bank Reference"}
+	}))
+	want := []string{
+		"01/06/2020,-4.01,,CARD,INV 1001,,Debit",
+		"02/06/2020,-4.01,,CARD,BR2,,Debit",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got rows %q, want %q", got, want)
+	}
+}
+
+func TestHasValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"4.01", true},
+		{" 4.01 ", true},
+		{"", false},
+		{"   ", false},
+		{"\t", false},
+		{"<nil>", false},
+		{" <nil> ", false},
+	}
+	for _, tt := range tests {
+		if got := hasValue(tt.value); got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSpacePaddedAmounts(t *testing.T) {
+	statement := statementHeader +
+		"01/06/2020,CARD,REF1,TESCO,   ,  4.01  ,1\n" +
+		"02/06/2020,CARD,REF2,TESCO, 2.50 ,   ,1\n" +
+		"03/06/2020,CARD,REF3,TESCO,<nil>,1.00,1\n"
+	got := outputRows(transformStatement(t, statement, nil))
+	want := []string{
+		"01/06/2020,4.01,,TESCO,CARD REF1,,Credit",
+		"02/06/2020,-2.50,,TESCO,CARD REF2,,Debit",
+		"03/06/2020,1.00,,TESCO,CARD REF3,,Credit",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got rows %q, want %q", got, want)
+	}
+}
+
+func TestJoinColumns(t *testing.T) {
+	data := map[string]string{"Description": "CARD", "Bank Reference": "REF1", "Empty": "", "Spaces": "   ", "Nil": "<nil>"}
+	tests := []struct {
+		columns []string
+		want    string
+	}{
+		{[]string{"Description", "Bank Reference"}, "CARD REF1"},
+		{[]string{"Empty", "Bank Reference"}, "REF1"},
+		{[]string{"Description", "Spaces", "Bank Reference"}, "CARD REF1"},
+		{[]string{"Empty", "Spaces", "Nil"}, ""},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := joinColumns(data, tt.columns); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.columns, got, tt.want)
+		}
+	}
+}
+
+func TestEmptyReferenceSources(t *testing.T) {
+	statement := statementHeader +
+		"01/06/2020,,,TESCO,4.01,,1\n" +
+		"02/06/2020,  ,\t,  ,4.01,,1\n"
+	got := outputRows(transformStatement(t, statement, nil))
+	want := []string{"01/06/2020,-4.01,,TESCO,,,Debit", "02/06/2020,-4.01,,,,,Debit"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got rows %q, want %q", got, want)
+	}
+}
+
+func TestBlankEmptyFields(t *testing.T) {
+	tr := &Transform{Payee: " ", Description: "\t", Reference: " REF1 ", ChequeNumber: "  "}
+	blankEmptyFields(tr)
+	want := &Transform{Reference: " REF1 "}
+	if !reflect.DeepEqual(tr, want) {
+		t.Errorf("got %+v, want %+v", tr, want)
+	}
+}
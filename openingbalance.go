@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Payee and Description of the opening balance row
+const openingBalanceText = "Opening Balance"
+
+// Row written ahead of the transactions to set up an account's opening balance, nil when not wanted
+var openingBalance *transaction
+
+// newOpeningBalance makes the opening balance row from a -emitopeningbalance value of "date" or "date,amount",
+// the amount falling back to the -openingbalance one
+func newOpeningBalance(spec string, fallbackAmount string) (*transaction, error) {
+	parts := strings.SplitN(spec, ",", 2)
+	amountText := fallbackAmount
+	if len(parts) == 2 {
+		if fallbackAmount != "" {
+			return nil, fmt.Errorf("give the opening balance either in -emitopeningbalance or in -openingbalance, not both")
+		}
+		amountText = parts[1]
+	}
+	if strings.TrimSpace(amountText) == "" {
+		return nil, fmt.Errorf("no opening balance amount, give -openingbalance or -emitopeningbalance date,amount")
+	}
+
+	date, err := parseDate(parts[0], "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid opening balance date %q: %s", parts[0], err)
+	}
+	amount, err := parseAmount(amountText)
+	if err != nil {
+		return nil, fmt.Errorf("invalid opening balance %q: %s", amountText, err)
+	}
+
+	t := &transaction{Transform: &Transform{
+		Date:            formatDate(date, outputDateFormat),
+		Payee:           openingBalanceText,
+		Description:     openingBalanceText,
+		TransactionType: creditType,
+	}, date: date}
+	t.setAmount(amount)
+	if amount < 0 {
+		t.TransactionType = debitType
+	}
+	return t, nil
+}
+
+// writeOpeningBalance writes the opening balance row first, leaving it out of the summary's
+// counts and totals unless -openingbalanceintotals is set
+func writeOpeningBalance(out transactionWriter) error {
+	summary.OpeningBalance = openingBalance.Amount
+	if openingBalanceInTotals {
+		return writeTransaction(out, openingBalance)
+	}
+	if err := out.Write(openingBalance); err != nil {
+		return fmt.Errorf("%w writing output: %s", ErrIO, err)
+	}
+	return nil
+}
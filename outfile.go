@@ -0,0 +1,208 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// What to do with the partial output when a run times out
+const (
+	onTimeoutKeep    = "keep"
+	onTimeoutDiscard = "discard"
+)
+
+// errOutputClosed is returned when writing after the output has been finished
+var errOutputClosed = errors.New("output already closed")
+
+var (
+	// Guards the output against a run left behind after a timeout
+	outputMu sync.Mutex
+	// Set once the output has been finished, after which nothing more is written
+	outputClosed bool
+)
+
+// Create missing parent directories of output files, rather than fail
+var mkdirOut bool
+
+// prepareOutputDir makes sure the directory an output file goes in exists, creating it when allowed
+func prepareOutputDir(path string) error {
+	if path == "" {
+		return nil
+	}
+	dir := filepath.Dir(path)
+	if mkdirOut {
+		return os.MkdirAll(dir, 0777)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("output directory %s does not exist, create it or use -mkdirout", dir)
+	}
+	return nil
+}
+
+// Every output file created, so they can all be committed or discarded together
+var outputFiles []*outputFile
+
+// outputFile is written under a temporary name next to its final path, and only renamed
+// into place once finished so nothing ever sees a half written file
+type outputFile struct {
+	*os.File
+	path string
+	// Written to in place, for named pipes and devices that can't be renamed over, and stdout
+	direct bool
+	// Standard output, written when there is no -outfile and left open once done
+	stdout bool
+	// Encoder written through, nil when writing UTF-8
+	encoder io.WriteCloser
+	// Whether the file has been committed or discarded
+	done bool
+}
+
+// isSpecialFile reports whether a path is an existing named pipe, device or the like rather than a regular file
+func isSpecialFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.Mode().IsRegular()
+}
+
+// openSpecialFile opens a named pipe or device for writing, without truncating it
+func openSpecialFile(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_WRONLY, 0)
+}
+
+// Name of the output written when there is no -outfile
+const stdoutName = "stdout"
+
+// createOutputFile creates the temporary file for an output path, or writes to stdout when there is no path
+func createOutputFile(path string) (*outputFile, error) {
+	if path == "" {
+		f := &outputFile{File: os.Stdout, path: stdoutName, direct: true, stdout: true}
+		outputFiles = append(outputFiles, f)
+		return f, nil
+	}
+	if isSpecialFile(path) {
+		// Whatever reads the pipe sees transactions as they are written
+		fh, err := openSpecialFile(path)
+		if err != nil {
+			return nil, err
+		}
+		f := &outputFile{File: fh, path: path, direct: true}
+		outputFiles = append(outputFiles, f)
+		return f, nil
+	}
+
+	fh, err := createTempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return nil, err
+	}
+
+	f := &outputFile{File: fh, path: path}
+	outputFiles = append(outputFiles, f)
+	return f, nil
+}
+
+// createTempFile creates a new uniquely named file to write an output into before it is renamed into place.
+// Unlike ioutil.TempFile, it is created with the usual 0666 permissions less the umask, which the rename keeps.
+func createTempFile(dir string, base string) (*os.File, error) {
+	for try := 0; ; try++ {
+		name := filepath.Join(dir, "."+base+"."+strconv.FormatUint(uint64(rand.Uint32()), 10)+".tmp")
+		fh, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if os.IsExist(err) && try < 10000 {
+			continue
+		}
+		return fh, err
+	}
+}
+
+// commit closes the file and moves it into place
+func (f *outputFile) commit() error {
+	if f == nil || f.done {
+		return nil
+	}
+	if f.encoder != nil {
+		if err := f.encoder.Close(); err != nil {
+			f.discard()
+			return fmt.Errorf("%w encoding %s: %s", ErrIO, f.path, err)
+		}
+	}
+	f.done = true
+	if f.stdout {
+		return nil
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if f.direct {
+		return nil
+	}
+	return os.Rename(f.Name(), f.path)
+}
+
+// discard closes and removes the file
+func (f *outputFile) discard() {
+	if f == nil || f.done {
+		return
+	}
+	f.done = true
+	if !f.stdout {
+		f.Close()
+	}
+	if f.direct {
+		log.Warningf("Unable to discard what was already written to %s", f.path)
+		return
+	}
+	os.Remove(f.Name())
+}
+
+// flushOutput flushes the output unless it has already been finished
+func flushOutput(out transactionWriter) error {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	if outputClosed {
+		return errOutputClosed
+	}
+	return out.Flush()
+}
+
+// finishOutput flushes the output for the last time, stopping any further writes
+func finishOutput(out transactionWriter) error {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	outputClosed = true
+	if rejectWriter != nil {
+		rejectWriter.Flush()
+	}
+	for _, e := range extraOutputs {
+		if err := e.out.Flush(); err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
+
+// commitOutputs moves every output into place
+func commitOutputs() error {
+	for _, f := range outputFiles {
+		if err := f.commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// discardOutputs removes every output not yet committed
+func discardOutputs() {
+	for _, f := range outputFiles {
+		f.discard()
+	}
+}
+
+// fatalOutput discards the outputs not yet committed and stops the run, as log.Fatal skips deferred cleanup
+func fatalOutput(args ...interface{}) {
+	discardOutputs()
+	log.Fatal(args...)
+}
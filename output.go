@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Output formats
+const (
+	formatCSV   = "csv"
+	formatQIF   = "qif"
+	formatFixed = "fixed"
+	formatJSON  = "json"
+)
+
+// transactionWriter writes transactions in one of the output formats
+type transactionWriter interface {
+	// WriteHeader writes anything needed before the first transaction
+	WriteHeader() error
+	// Write writes a single transaction
+	Write(t *transaction) error
+	// Flush writes any buffered output to the underlying writer
+	Flush() error
+}
+
+// newTransactionWriter creates a writer for the named output format
+func newTransactionWriter(format string, w io.Writer) (transactionWriter, error) {
+	switch format {
+	case formatCSV:
+		return &csvTransactionWriter{csvw: csv.NewWriter(w), w: w}, nil
+	case formatQIF:
+		return &qifTransactionWriter{w: bufio.NewWriter(w), dateLayout: qifDateLayout(outputDateFormat)}, nil
+	case formatFixed:
+		return &fixedTransactionWriter{w: bufio.NewWriter(w), layout: fixedLayout}, nil
+	case formatJSON:
+		buffered := bufio.NewWriter(w)
+		return &jsonTransactionWriter{w: buffered, enc: json.NewEncoder(buffered)}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q, expected %s, %s, %s or %s", format, formatCSV, formatQIF, formatFixed, formatJSON)
+}
+
+// outputTargets pairs each of the comma separated output formats with its output file. Several formats
+// take either as many comma separated files, or a single file name with {format} in it.
+func outputTargets(formatList string, pathList string) ([]string, []string, error) {
+	formats := strings.Split(formatList, ",")
+	if len(formats) == 1 {
+		return formats, []string{pathList}, nil
+	}
+	var paths []string
+	if strings.Contains(pathList, "{format}") {
+		for _, format := range formats {
+			paths = append(paths, strings.Replace(pathList, "{format}", format, -1))
+		}
+		return formats, paths, nil
+	}
+	paths = strings.Split(pathList, ",")
+	if len(paths) != len(formats) {
+		return nil, nil, fmt.Errorf("%d output formats need as many -outfile names, or {format} in the name, got %q", len(formats), pathList)
+	}
+	return formats, paths, nil
+}
+
+// multiTransactionWriter writes every transaction to several writers
+type multiTransactionWriter []transactionWriter
+
+func (m multiTransactionWriter) WriteHeader() error {
+	for _, w := range m {
+		if err := w.WriteHeader(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m multiTransactionWriter) Write(t *transaction) error {
+	for _, w := range m {
+		if err := w.Write(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m multiTransactionWriter) WriteSeparator() error {
+	for _, w := range m {
+		if err := writeGroupSeparator(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m multiTransactionWriter) Flush() error {
+	for _, w := range m {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Orders a leading index column can number transactions in
+const (
+	indexOutput = "output"
+	indexSource = "source"
+)
+
+// Order the leading index column numbers transactions in, empty for no index column
+var indexOrder string
+
+// Layouts of the amount in CSV output
+const (
+	// A single signed Amount column
+	layoutSigned = "signed"
+	// Separate Spent and Received columns, each unsigned, with the other left empty
+	layoutSpentReceived = "spentreceived"
+)
+
+// Layout of the amount in CSV output
+var amountLayout = layoutSigned
+
+// csvTransactionWriter writes transactions in Xero's CSV import format
+type csvTransactionWriter struct {
+	csvw *csv.Writer
+	// Writer under csvw, for the blank lines between groups
+	w io.Writer
+	// Rows written so far
+	rows int
+}
+
+func (w *csvTransactionWriter) WriteHeader() error {
+	var headers []string
+	if indexOrder != "" {
+		headers = append(headers, "Index")
+	}
+	for _, header := range xeroCSVHeaders {
+		if header == "*Amount" && amountLayout == layoutSpentReceived {
+			headers = append(headers, "Spent", "Received")
+			continue
+		}
+		headers = append(headers, header)
+	}
+	for _, column := range extraColumns {
+		headers = append(headers, column.header)
+	}
+	return w.csvw.Write(headers)
+}
+
+func (w *csvTransactionWriter) Write(t *transaction) error {
+	w.rows++
+	var row []string
+	switch indexOrder {
+	case indexOutput:
+		row = append(row, strconv.Itoa(w.rows))
+	case indexSource:
+		row = append(row, strconv.Itoa(t.index))
+	}
+	row = append(row, t.Date)
+	if amountLayout == layoutSpentReceived {
+		spent, received := spentReceived(t)
+		row = append(row, spent, received)
+	} else {
+		row = append(row, t.Amount)
+	}
+	row = append(row,
+		t.Payee,
+		t.Description,
+		t.Reference,
+		t.ChequeNumber,
+		t.TransactionType,
+	)
+	for _, column := range extraColumns {
+		row = append(row, column.value(t))
+	}
+	return w.csvw.Write(row)
+}
+
+// spentReceived splits the amount of a transaction into the Spent and Received columns
+func spentReceived(t *transaction) (string, string) {
+	switch {
+	case !t.hasAmount:
+		return "", ""
+	case t.amount < 0:
+		return formatAmount(-t.amount), ""
+	}
+	return "", t.Amount
+}
+
+// WriteSeparator writes a blank line, as the csv package can't write a record without cells
+func (w *csvTransactionWriter) WriteSeparator() error {
+	w.csvw.Flush()
+	if err := w.csvw.Error(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w.w, "\n")
+	return err
+}
+
+func (w *csvTransactionWriter) Flush() error {
+	w.csvw.Flush()
+	return w.csvw.Error()
+}
+
+// jsonTransactionWriter writes each transaction as a JSON object on its own line
+type jsonTransactionWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (w *jsonTransactionWriter) WriteHeader() error {
+	return nil
+}
+
+func (w *jsonTransactionWriter) Write(t *transaction) error {
+	return w.enc.Encode(t.Transform)
+}
+
+func (w *jsonTransactionWriter) Flush() error {
+	return w.w.Flush()
+}
+
+// qifTransactionWriter writes transactions as a QIF bank account section
+type qifTransactionWriter struct {
+	w *bufio.Writer
+	// Go time layout of QIF dates
+	dateLayout string
+}
+
+func (w *qifTransactionWriter) WriteHeader() error {
+	_, err := w.w.WriteString("!Type:Bank\n")
+	return err
+}
+
+func (w *qifTransactionWriter) Write(t *transaction) error {
+	date := t.Date
+	if !t.date.IsZero() {
+		date = t.date.Format(w.dateLayout)
+	}
+
+	var memo []string
+	for _, value := range []string{t.Description, t.Reference} {
+		if value = strings.TrimSpace(value); value != "" {
+			memo = append(memo, value)
+		}
+	}
+
+	fmt.Fprintf(w.w, "D%s\n", date)
+	fmt.Fprintf(w.w, "T%s\n", t.Amount)
+	if t.Payee != "" {
+		fmt.Fprintf(w.w, "P%s\n", qifValue(t.Payee))
+	}
+	if len(memo) > 0 {
+		fmt.Fprintf(w.w, "M%s\n", qifValue(strings.Join(memo, " ")))
+	}
+	if t.ChequeNumber != "" {
+		fmt.Fprintf(w.w, "N%s\n", qifValue(t.ChequeNumber))
+	}
+	_, err := w.w.WriteString("^\n")
+	return err
+}
+
+func (w *qifTransactionWriter) Flush() error {
+	return w.w.Flush()
+}
+
+// qifValue keeps a value on a single QIF line
+func qifValue(value string) string {
+	return strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(value)
+}
+
+// qifDateLayout picks the QIF date layout matching the day/month order of the output date layout,
+// as QIF readers expect either DD/MM/YYYY or MM/DD/YYYY
+func qifDateLayout(layout string) string {
+	layout = strings.Replace(layout, "2006", "", -1)
+	month := strings.IndexAny(layout, "1J")
+	day := strings.Index(layout, "2")
+	if month >= 0 && day >= 0 && month < day {
+		return "01/02/2006"
+	}
+	return "02/01/2006"
+}
+
+// outputColumn is an optional column appended to the CSV output
+type outputColumn struct {
+	header string
+	value  func(t *transaction) string
+}
+
+// Optional columns appended to the CSV output, in order
+var extraColumns []outputColumn
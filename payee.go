@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// payeeFallback fills in an empty Payee
+type payeeFallback struct {
+	// Field whose first word is used, empty to use the literal
+	field   string
+	literal string
+}
+
+// Fallback for empty payees, nil to leave them empty
+var payeeDefault *payeeFallback
+
+// parsePayeeFallback reads a -payeefallback value: "none", "firstword" or "firstword:Field", or a literal payee
+func parsePayeeFallback(spec string) (*payeeFallback, error) {
+	switch {
+	case spec == "none" || spec == "":
+		return nil, nil
+	case spec == "firstword":
+		return &payeeFallback{field: "Description"}, nil
+	case strings.HasPrefix(spec, "firstword:"):
+		field := strings.TrimPrefix(spec, "firstword:")
+		if transformFieldRef(&Transform{}, field) == nil {
+			return nil, fmt.Errorf("unknown field %q in -payeefallback, expected one of %s", field, strings.Join(transformFieldNames, ", "))
+		}
+		return &payeeFallback{field: field}, nil
+	}
+	return &payeeFallback{literal: spec}, nil
+}
+
+// apply fills in the Payee of a transaction when it's empty
+func (f *payeeFallback) apply(t *Transform) {
+	if strings.TrimSpace(t.Payee) != "" {
+		return
+	}
+	if f.field == "" {
+		t.Payee = f.literal
+		return
+	}
+	value, _ := transformField(t, f.field)
+	if words := strings.Fields(value); len(words) > 0 {
+		t.Payee = words[0]
+	}
+}
+
+// payeeAlias maps payees matching a pattern to a canonical payee
+type payeeAlias struct {
+	pattern *regexp.Regexp
+	payee   string
+}
+
+// Aliases unifying payee names, in the order they are tried
+var payeeAliases []payeeAlias
+
+// loadPayeeAliases reads a CSV of pattern,payee lines, each pattern being a regular expression
+func loadPayeeAliases(r io.Reader) ([]payeeAlias, error) {
+	csvr := csv.NewReader(r)
+	csvr.FieldsPerRecord = 2
+	csvr.TrimLeadingSpace = true
+
+	var aliases []payeeAlias
+	for line := 1; ; line++ {
+		row, err := csvr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		pattern, err := regexp.Compile(row[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern on line %d: %s", line, err)
+		}
+		aliases = append(aliases, payeeAlias{pattern: pattern, payee: strings.TrimSpace(row[1])})
+	}
+	return aliases, nil
+}
+
+// applyPayeeAliases replaces the Payee of a transaction with the canonical payee of the first matching alias
+func applyPayeeAliases(t *Transform, aliases []payeeAlias, line int) {
+	if strings.TrimSpace(t.Payee) == "" {
+		return
+	}
+	for _, alias := range aliases {
+		if alias.pattern.MatchString(t.Payee) {
+			t.Payee = alias.payee
+			return
+		}
+	}
+	log.Debugf("No payee alias matches %q on line %d", t.Payee, line)
+}
+
+// Payees that are noise rather than a name, such as "POS", blanked so Xero doesn't create contacts for them
+var junkPayees stringList
+
+// blankJunkPayee empties the Payee of a transaction when it is one of the junk payees, ignoring case
+func blankJunkPayee(t *Transform, junk []string, line int) {
+	payee := strings.TrimSpace(t.Payee)
+	if payee == "" {
+		return
+	}
+	for _, value := range junk {
+		if strings.EqualFold(payee, strings.TrimSpace(value)) {
+			log.Noticef("Blanking the junk payee %q on line %d", t.Payee, line)
+			t.Payee = ""
+			return
+		}
+	}
+}
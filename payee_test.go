@@ -0,0 +1,87 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBlankJunkPayee(t *testing.T) {
+	junk := []string{"POS", " payment "}
+	tests := []struct {
+		payee string
+		want  string
+	}{
+		{"POS", ""},
+		{"pos", ""},
+		{" Payment ", ""},
+		{"POS TESCO", "POS TESCO"},
+		{"TESCO", "TESCO"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		tr := &Transform{Payee: tt.payee}
+		blankJunkPayee(tr, junk, 1)
+		if tr.Payee != tt.want {
+			t.Errorf("payee %q: got %q, want %q", tt.payee, tr.Payee, tt.want)
+		}
+	}
+}
+
+func TestJunkPayeeFallback(t *testing.T) {
+	setForTest(t, &junkPayees, stringList{"POS"})
+	setForTest(t, &payeeDefault, &payeeFallback{field: "Reference"})
+	statement := statementHeader +
+		"01/06/2020,CARD,REF1,TESCO,4.01,,1\n"
+	payeeFromDescription := func(p *Preset) {
+		p.Columns.Payee = []string{"Customer Reference"}
+		p.Columns.Description = nil
+	}
+	got := outputRows(transformStatement(t, statement, payeeFromDescription))
+	want := []string{"01/06/2020,-4.01,TESCO,,CARD REF1,,Debit"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got rows %q, want %q", got, want)
+	}
+
+	junkStatement := statementHeader +
+		"01/06/2020,CARD,REF1,POS,4.01,,1\n"
+	got = outputRows(transformStatement(t, junkStatement, payeeFromDescription))
+	want = []string{"01/06/2020,-4.01,CARD,,CARD REF1,,Debit"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("junk payee: got rows %q, want %q", got, want)
+	}
+}
+
+func TestPayeeFallback(t *testing.T) {
+	tests := []struct {
+		spec    string
+		payee   string
+		want    string
+		wantErr bool
+	}{
+		{"none", "", "", false},
+		{"", "", "", false},
+		{"Unknown payee", "", "Unknown payee", false},
+		{"Unknown payee", "TESCO", "TESCO", false},
+		{"firstword", "", "CARD", false},
+		{"firstword", "  ", "CARD", false},
+		{"firstword:Reference", "", "REF1", false},
+		{"firstword:Reference", "TESCO", "TESCO", false},
+		{"firstword:ChequeNumber", "", "", false},
+		{"firstword:Nowhere", "", "", true},
+	}
+	for _, tt := range tests {
+		fallback, err := parsePayeeFallback(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: got error %v, want error %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		tr := &Transform{Payee: tt.payee, Description: "CARD PAYMENT", Reference: "REF1 TESCO"}
+		if fallback != nil {
+			fallback.apply(tr)
+		}
+		if strings.TrimSpace(tr.Payee) != tt.want {
+			t.Errorf("%q on payee %q: got %q, want %q", tt.spec, tt.payee, tr.Payee, tt.want)
+		}
+	}
+}
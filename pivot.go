@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Row of the pivot report gathering transactions no category keyword matches
+const otherCategory = "Other"
+
+// Column of the pivot report gathering transactions without a usable date
+const unknownMonth = "Unknown"
+
+// categoryRule puts transactions whose text holds a keyword into a category
+type categoryRule struct {
+	keyword  string
+	category string
+}
+
+// Keyword rules categorising transactions, in the order they are tried
+var categoryRules []categoryRule
+
+// loadCategoryRules reads a CSV of keyword,category lines. Keywords match the Payee, Description
+// or Reference anywhere, whatever their case.
+func loadCategoryRules(r io.Reader) ([]categoryRule, error) {
+	csvr := csv.NewReader(r)
+	csvr.FieldsPerRecord = 2
+	csvr.TrimLeadingSpace = true
+
+	var rules []categoryRule
+	for line := 1; ; line++ {
+		row, err := csvr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		keyword := strings.ToLower(strings.TrimSpace(row[0]))
+		if keyword == "" {
+			return nil, fmt.Errorf("empty keyword on line %d", line)
+		}
+		rules = append(rules, categoryRule{keyword: keyword, category: strings.TrimSpace(row[1])})
+	}
+	return rules, nil
+}
+
+// categorize returns the category of the first rule whose keyword a transaction's text holds
+func categorize(t *Transform, rules []categoryRule) string {
+	text := strings.ToLower(strings.Join([]string{t.Payee, t.Description, t.Reference}, "\x00"))
+	for _, rule := range rules {
+		if strings.Contains(text, rule.keyword) {
+			return rule.category
+		}
+	}
+	return otherCategory
+}
+
+// pivotTotals nets written transactions by category and month, keyed by category then "2006-01" month
+type pivotTotals map[string]map[string]int64
+
+// Pivot report of net amounts by category and month, nil when not wanted
+var pivotReport pivotTotals
+
+// add nets a transaction into its category's total for its month
+func (p pivotTotals) add(t *transaction) {
+	if !t.hasAmount {
+		return
+	}
+	month := unknownMonth
+	if !t.date.IsZero() {
+		month = t.date.Format("2006-01")
+	}
+	category := categorize(t.Transform, categoryRules)
+	if p[category] == nil {
+		p[category] = map[string]int64{}
+	}
+	p[category][month] += t.amount
+}
+
+// write writes the report as CSV, a row per category in name order with Other last, and a column per
+// month in date order with Unknown last. Cells of months without transactions in a category are empty.
+func (p pivotTotals) write(w io.Writer) error {
+	var categories []string
+	monthSeen := map[string]bool{}
+	for category, months := range p {
+		if category != otherCategory {
+			categories = append(categories, category)
+		}
+		for month := range months {
+			monthSeen[month] = true
+		}
+	}
+	sort.Strings(categories)
+	if _, ok := p[otherCategory]; ok {
+		categories = append(categories, otherCategory)
+	}
+	var months []string
+	for month := range monthSeen {
+		if month != unknownMonth {
+			months = append(months, month)
+		}
+	}
+	sort.Strings(months)
+	if monthSeen[unknownMonth] {
+		months = append(months, unknownMonth)
+	}
+
+	csvw := csv.NewWriter(w)
+	csvw.Write(append([]string{"Category"}, months...))
+	for _, category := range categories {
+		row := []string{category}
+		for _, month := range months {
+			cell := ""
+			if net, ok := p[category][month]; ok {
+				cell = formatAmount(net)
+			}
+			row = append(row, cell)
+		}
+		csvw.Write(row)
+	}
+	csvw.Flush()
+	return csvw.Error()
+}
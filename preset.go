@@ -0,0 +1,328 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Preset bundles the settings needed to read a particular bank's statement export
+type Preset struct {
+	// Field delimiter used in the export, "tab" for tab separated files
+	Delimiter string `json:"delimiter"`
+	// Go time layout of dates in the export, empty to pass dates through unchanged
+	DateFormat string `json:"dateFormat"`
+	// Leading header cells identifying the header row among any preamble
+	HeaderSignature []string `json:"headerSignature"`
+	// Rows marking the start of the transactions section, matched case-insensitively
+	SectionMarkers []string `json:"sectionMarkers"`
+	// Ignore everything before the first section marker, including header-like rows
+	SkipToMarker bool `json:"skipToMarker"`
+	// Rows marking the footer after the transactions, matched case-insensitively with the start of the row
+	EndMarkers []string `json:"endMarkers,omitempty"`
+	// Source columns used for each Xero field
+	Columns ColumnMapping `json:"columns"`
+	// Ways of reading amounts to try in order, the first giving a valid amount being used for the whole
+	// statement: "separate", "signed", "suffix", "indicator" or "direction". Empty to read the amount
+	// column, then let the debit and credit columns override it.
+	AmountStrategies []string `json:"amountStrategies,omitempty"`
+	// Payee of every transaction whose payee columns are empty, for statements of a single payee
+	ConstantPayee string `json:"constantPayee,omitempty"`
+	// Values of the indicator column marking debits and credits, matched case-insensitively
+	DebitIndicators  []string `json:"debitIndicators"`
+	CreditIndicators []string `json:"creditIndicators"`
+	// Values of the direction column saying a transaction is or isn't a debit, matched case-insensitively
+	DirectionDebit  []string `json:"directionDebit"`
+	DirectionCredit []string `json:"directionCredit"`
+	// Header names renamed after collapsing their whitespace, on top of the default aliases
+	HeaderAliases map[string]string `json:"headerAliases"`
+	// Columns found in the statement a config was generated from, for reference only
+	SourceColumns []string `json:"sourceColumns,omitempty"`
+}
+
+// ColumnMapping names the source columns used to build each Xero field.
+// Text fields built from several columns are joined with a space. A text column may list alternatives
+// separated by "|", such as "Customer Reference|Bank Reference", to use the first of them with a value.
+type ColumnMapping struct {
+	Date         string   `json:"date"`
+	Debit        string   `json:"debit"`
+	Credit       string   `json:"credit"`
+	Amount       string   `json:"amount"`
+	Indicator    string   `json:"indicator"`
+	Currency     string   `json:"currency"`
+	Balance      string   `json:"balance"`
+	Direction    string   `json:"direction"`
+	Payee        []string `json:"payee"`
+	Description  []string `json:"description"`
+	Reference    []string `json:"reference"`
+	ChequeNumber []string `json:"chequeNumber"`
+}
+
+// defaultHeaderAliases rename oddly spaced headers of the default bank format
+var defaultHeaderAliases = map[string]string{
+	" Date":               "Date",
+	"Bank     Reference":  "Bank Reference",
+	"Customer  Reference": "Customer Reference",
+	"Running  Balance  ":  "Running Balance",
+}
+
+// defaultPresetName is the preset used when no bank is given
+const defaultPresetName = "default"
+
+// builtinPresets are the bank presets shipped with the tool
+var builtinPresets = map[string]Preset{
+	defaultPresetName: {
+		Delimiter:       ",",
+		HeaderSignature: []string{" Date", "Description"},
+		SectionMarkers:  []string{"Transactions"},
+		Columns: ColumnMapping{
+			Date:        "Date",
+			Debit:       "Debit",
+			Credit:      "Credit",
+			Balance:     "Running Balance",
+			Description: []string{"Customer Reference"},
+			Reference:   []string{"Description", "Bank Reference"},
+		},
+	},
+	"barclays-uk": {
+		Delimiter:       ",",
+		DateFormat:      "02/01/2006",
+		HeaderSignature: []string{"Number", "Date", "Account", "Amount"},
+		Columns: ColumnMapping{
+			Date:        "Date",
+			Amount:      "Amount",
+			Description: []string{"Memo"},
+			Reference:   []string{"Subcategory"},
+		},
+	},
+	"monzo": {
+		Delimiter:       ",",
+		DateFormat:      "02/01/2006",
+		HeaderSignature: []string{"Transaction ID", "Date", "Time", "Type"},
+		Columns: ColumnMapping{
+			Date:        "Date",
+			Amount:      "Amount",
+			Payee:       []string{"Name"},
+			Description: []string{"Description"},
+			Reference:   []string{"Notes and #tags"},
+		},
+	},
+	"starling": {
+		Delimiter:       ",",
+		DateFormat:      "02/01/2006",
+		HeaderSignature: []string{"Date", "Counter Party", "Reference", "Type"},
+		Columns: ColumnMapping{
+			Date:        "Date",
+			Amount:      "Amount (GBP)",
+			Payee:       []string{"Counter Party"},
+			Description: []string{"Notes"},
+			Reference:   []string{"Reference"},
+		},
+	},
+}
+
+// presetFlags copies the preset setting behind each command line flag, so explicitly set flags
+// can override individual values of the selected preset
+var presetFlags = map[string]func(dst, src *Preset){
+	"delimiter":          func(dst, src *Preset) { dst.Delimiter = src.Delimiter },
+	"dateformat":         func(dst, src *Preset) { dst.DateFormat = src.DateFormat },
+	"headersignature":    func(dst, src *Preset) { dst.HeaderSignature = src.HeaderSignature },
+	"sectionmarkers":     func(dst, src *Preset) { dst.SectionMarkers = src.SectionMarkers },
+	"skiptomarker":       func(dst, src *Preset) { dst.SkipToMarker = src.SkipToMarker },
+	"endmarkers":         func(dst, src *Preset) { dst.EndMarkers = src.EndMarkers },
+	"amountstrategies":   func(dst, src *Preset) { dst.AmountStrategies = src.AmountStrategies },
+	"payee":              func(dst, src *Preset) { dst.ConstantPayee = src.ConstantPayee },
+	"datecolumn":         func(dst, src *Preset) { dst.Columns.Date = src.Columns.Date },
+	"debitcolumn":        func(dst, src *Preset) { dst.Columns.Debit = src.Columns.Debit },
+	"creditcolumn":       func(dst, src *Preset) { dst.Columns.Credit = src.Columns.Credit },
+	"amountcolumn":       func(dst, src *Preset) { dst.Columns.Amount = src.Columns.Amount },
+	"indicatorcolumn":    func(dst, src *Preset) { dst.Columns.Indicator = src.Columns.Indicator },
+	"debitindicator":     func(dst, src *Preset) { dst.DebitIndicators = src.DebitIndicators },
+	"creditindicator":    func(dst, src *Preset) { dst.CreditIndicators = src.CreditIndicators },
+	"currencycolumn":     func(dst, src *Preset) { dst.Columns.Currency = src.Columns.Currency },
+	"balancecolumn":      func(dst, src *Preset) { dst.Columns.Balance = src.Columns.Balance },
+	"directioncolumn":    func(dst, src *Preset) { dst.Columns.Direction = src.Columns.Direction },
+	"directiondebit":     func(dst, src *Preset) { dst.DirectionDebit = src.DirectionDebit },
+	"directioncredit":    func(dst, src *Preset) { dst.DirectionCredit = src.DirectionCredit },
+	"payeecolumns":       func(dst, src *Preset) { dst.Columns.Payee = src.Columns.Payee },
+	"descriptioncolumns": func(dst, src *Preset) { dst.Columns.Description = src.Columns.Description },
+	"referencecolumns":   func(dst, src *Preset) { dst.Columns.Reference = src.Columns.Reference },
+	"chequecolumns":      func(dst, src *Preset) { dst.Columns.ChequeNumber = src.Columns.ChequeNumber },
+}
+
+// loadPresets returns the built-in presets along with any user presets found as JSON, YAML or TOML files in dir.
+// A user preset is named after its file and replaces a built-in preset of the same name.
+func loadPresets(dir string) (map[string]Preset, error) {
+	presets := map[string]Preset{}
+	for name, preset := range builtinPresets {
+		presets[name] = preset
+	}
+	if dir == "" {
+		return presets, nil
+	}
+
+	var paths []string
+	for _, ext := range configExtensions {
+		matches, err := filepath.Glob(filepath.Join(dir, "*"+ext))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+	for _, path := range paths {
+		preset, err := loadPresetFile(path)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		presets[name] = preset
+	}
+
+	return presets, nil
+}
+
+// loadPresetFile reads a preset from a JSON, YAML or TOML file, refusing settings it doesn't know
+func loadPresetFile(path string) (Preset, error) {
+	var preset Preset
+	content, err := readConfigFile(path)
+	if err != nil {
+		return preset, fmt.Errorf("invalid preset %s: %s", path, err)
+	}
+	if err := decodeConfig(content, &preset); err != nil {
+		return preset, fmt.Errorf("invalid preset %s: %s", path, err)
+	}
+	return preset, nil
+}
+
+// generateConfig writes a config skeleton for a statement, listing its columns with every mapping left empty
+func generateConfig(in input, preset *Preset, delimiter rune, path string) {
+	defer in.reader.Close()
+	headers, err := readHeader(newStatementReader(in.reader, delimiter), in.name, preset)
+	if err != nil {
+		exitWith(exitCode(err), err)
+	}
+
+	skeleton := Preset{
+		Delimiter:        preset.Delimiter,
+		DateFormat:       preset.DateFormat,
+		HeaderSignature:  preset.HeaderSignature,
+		SectionMarkers:   preset.SectionMarkers,
+		SkipToMarker:     preset.SkipToMarker,
+		EndMarkers:       preset.EndMarkers,
+		HeaderAliases:    map[string]string{},
+		DebitIndicators:  []string{},
+		CreditIndicators: []string{},
+		DirectionDebit:   []string{},
+		DirectionCredit:  []string{},
+		SourceColumns:    headers,
+		Columns: ColumnMapping{
+			Payee:        []string{},
+			Description:  []string{},
+			Reference:    []string{},
+			ChequeNumber: []string{},
+		},
+	}
+	content, err := json.MarshalIndent(skeleton, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, append(content, '\n'), 0666); err != nil {
+		log.Fatal(err)
+	}
+	log.Noticef("Config skeleton with %d source columns written to %s", len(headers), path)
+}
+
+// presetNames lists the available preset names in order
+func presetNames(presets map[string]Preset) []string {
+	var names []string
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// delimiterRune converts a delimiter setting into the rune used by the CSV reader
+func delimiterRune(delimiter string) (rune, error) {
+	switch delimiter {
+	case "", ",":
+		return ',', nil
+	case "tab", `\t`:
+		return '\t', nil
+	}
+	r, size := utf8.DecodeRuneInString(delimiter)
+	if size != len(delimiter) {
+		return 0, fmt.Errorf("delimiter %q must be a single character", delimiter)
+	}
+	return r, nil
+}
+
+// matchesSignature reports whether a row starts with the header signature, ignoring surrounding spaces
+func matchesSignature(row []string, signature []string) bool {
+	if len(signature) == 0 || len(row) < len(signature) {
+		return false
+	}
+	for i, cell := range signature {
+		if strings.TrimSpace(row[i]) != strings.TrimSpace(cell) {
+			return false
+		}
+	}
+	return true
+}
+
+// isSectionMarker reports whether a cell is one of the section marker words
+func isSectionMarker(cell string, markers []string) bool {
+	cell = strings.TrimSpace(cell)
+	for _, marker := range markers {
+		if strings.EqualFold(cell, strings.TrimSpace(marker)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isEndMarker reports whether a row starts with one of the end marker words
+func isEndMarker(row []string, markers []string) bool {
+	if len(row) == 0 {
+		return false
+	}
+	cell := strings.ToLower(strings.TrimSpace(row[0]))
+	for _, marker := range markers {
+		marker = strings.ToLower(strings.TrimSpace(marker))
+		if marker != "" && strings.HasPrefix(cell, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// repeatedList is a flag collecting every value it is given, for flags that may be repeated
+type repeatedList []string
+
+func (l *repeatedList) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *repeatedList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// stringList is a flag holding a comma separated list of values
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = nil
+	for _, v := range strings.Split(value, ",") {
+		*l = append(*l, v)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestIsSectionMarker(t *testing.T) {
+	markers := []string{"Transactions", " Statement entries "}
+	tests := []struct {
+		cell string
+		want bool
+	}{
+		{"Transactions", true},
+		{"TRANSACTIONS", true},
+		{"  transactions ", true},
+		{"Statement Entries", true},
+		{"Statement", false},
+		{"Transactions list", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isSectionMarker(tt.cell, markers); got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.cell, got, tt.want)
+		}
+	}
+}
+
+func TestSectionMarkers(t *testing.T) {
+	header := " Date,Description,Bank     Reference,Customer  Reference,Debit,Credit,Running  Balance  \n"
+	row := "01/06/2020,CARD,REF1,TESCO,4.01,,1\n"
+	tests := []struct {
+		name      string
+		markers   []string
+		skip      bool
+		statement string
+		wantRows  int
+		wantErr   error
+	}{
+		{"marker rows are skipped whatever their case", []string{"Statement entries"}, false,
+			header + "STATEMENT ENTRIES,,,,,,\n" + row, 1, nil},
+		{"a header before the marker is ignored", []string{"Statement entries"}, true,
+			header + "01/05/2020,OLD,REF0,OLD,1.00,,1\n" + "statement entries,,,,,,\n" + header + row, 1, nil},
+		{"a missing marker is an error", []string{"Statement entries"}, true,
+			header + row, 0, ErrNoHeader},
+	}
+	for _, tt := range tests {
+		output, err := transformStatementErr(tt.statement, func(p *Preset) {
+			p.SectionMarkers = tt.markers
+			p.SkipToMarker = tt.skip
+		})
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: got error %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got := outputRows(output); len(got) != tt.wantRows {
+			t.Errorf("%s: got rows %q, want %d", tt.name, got, tt.wantRows)
+		}
+	}
+	if got := outputRows(transformStatement(t, statementHeader+row, nil)); !reflect.DeepEqual(got, []string{"01/06/2020,-4.01,,TESCO,CARD REF1,,Debit"}) {
+		t.Errorf("default marker: got rows %q", got)
+	}
+}
+
+func TestIsEndMarker(t *testing.T) {
+	markers := []string{"Total", " End of statement ", ""}
+	tests := []struct {
+		row  []string
+		want bool
+	}{
+		{[]string{"Total", "", "100.00"}, true},
+		{[]string{"TOTALS"}, true},
+		{[]string{"  end of statement - thank you"}, true},
+		{[]string{"01/06/2020", "Total"}, false},
+		{[]string{""}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := isEndMarker(tt.row, markers); got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.row, got, tt.want)
+		}
+	}
+}
+
+func TestEndMarkerFooter(t *testing.T) {
+	statement := statementHeader +
+		"01/06/2020,CARD,REF1,TESCO,4.01,,1\n" +
+		"Totals,,,,4.01,0.00,\n" +
+		"02/06/2020,NOT A TRANSACTION,,,1.00,,\n" +
+		"Disclaimer: balances are provisional,,,,,,\n"
+	got := outputRows(transformStatement(t, statement, func(p *Preset) { p.EndMarkers = []string{"Totals"} }))
+	want := []string{"01/06/2020,-4.01,,TESCO,CARD REF1,,Debit"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got rows %q, want %q", got, want)
+	}
+	if summary.Read != 1 {
+		t.Errorf("got %d read, want 1", summary.Read)
+	}
+}
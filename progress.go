@@ -0,0 +1,87 @@
+package main
+
+import (
+	"sync"
+)
+
+// Progress is a snapshot of the counts of a run in progress
+type Progress struct {
+	// Source rows read past the header, whatever became of them
+	Rows     int
+	Read     int
+	Written  int
+	Skipped  int
+	Rejected int
+}
+
+// ProgressHook has a run call Func with its progress every Every source rows. The zero value reports nothing.
+type ProgressHook struct {
+	Every int
+	Func  func(Progress)
+}
+
+var (
+	// Source rows between progress log lines, as set by -progress
+	progressEvery int
+	// Guards progress, which is published for reading outside the run
+	progressMu sync.Mutex
+	progress   Progress
+	// Rejected rows and warnings of the run as of the last progress published, guarded by progressMu
+	publishedIssues   []Issue
+	publishedWarnings []Issue
+	// Set once the run has been abandoned while blocked reading its input, after which
+	// only the progress it last published may be read
+	runAbandoned bool
+)
+
+// CurrentProgress returns the counts of the run as of the last source row read.
+// It is safe to call while the run is going on.
+func CurrentProgress() Progress {
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	return progress
+}
+
+// publishProgress publishes the counts of the summary, counting another row if rowRead is true,
+// and calls the hook when another hook.Every rows have been read
+func publishProgress(rowRead bool, hook ProgressHook) {
+	progressMu.Lock()
+	if rowRead {
+		progress.Rows++
+	}
+	progress.Read = summary.Read
+	progress.Written = summary.Written
+	progress.Skipped = summary.Skipped
+	progress.Rejected = summary.Rejected
+	// Only ever appended to, so the run can carry on while the issues up to now are read
+	publishedIssues = summary.Issues[:len(summary.Issues):len(summary.Issues)]
+	publishedWarnings = summary.Warnings[:len(summary.Warnings):len(summary.Warnings)]
+	p := progress
+	progressMu.Unlock()
+
+	if rowRead && hook.Func != nil && hook.Every > 0 && p.Rows%hook.Every == 0 {
+		hook.Func(p)
+	}
+}
+
+// finalSummary returns the summary to report at the end of the run. An abandoned run may still
+// be writing its summary, so only the counts, rejected rows and warnings it last published are
+// reported for it.
+func finalSummary() *Summary {
+	if !runAbandoned {
+		return &summary
+	}
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	return &Summary{
+		RunID:       summary.RunID,
+		Environment: summary.Environment,
+		Started:     summary.Started,
+		Read:        progress.Read,
+		Written:     progress.Written,
+		Skipped:     progress.Skipped,
+		Rejected:    progress.Rejected,
+		Issues:      publishedIssues,
+		Warnings:    publishedWarnings,
+	}
+}
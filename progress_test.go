@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressHook(t *testing.T) {
+	startRun()
+	preset := builtinPresets[defaultPresetName]
+	statement := statementHeader +
+		"01/06/2020,A,R1,X,1.00,,1\n" +
+		"02/06/2020,B,R2,X,1.00,,1\n" +
+		"03/06/2020,C,R3,X,1.00,,1\n" +
+		"04/06/2020,D,R4,X,1.00,,1\n" +
+		"05/06/2020,E,R5,X,1.00,,1\n"
+	var reports []Progress
+	hook := ProgressHook{Every: 2, Func: func(p Progress) { reports = append(reports, p) }}
+	out, err := newTransactionWriter(formatCSV, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := input{name: "test.csv", reader: ioutil.NopCloser(strings.NewReader(statement))}
+	if err := Run(context.Background(), []input{in}, &preset, ',', out, hook); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 2 || reports[0].Rows != 2 || reports[1].Rows != 4 {
+		t.Fatalf("got progress %+v, want reports at rows 2 and 4", reports)
+	}
+	if reports[1].Written != 3 {
+		t.Errorf("got %d written at row 4, want 3", reports[1].Written)
+	}
+	if got := CurrentProgress(); got.Written != 5 {
+		t.Errorf("got %d written once done, want 5", got.Written)
+	}
+}
+
+// blockingReader hands out a statement, then blocks as an input waiting for more would
+type blockingReader struct {
+	statement *strings.Reader
+	block     chan struct{}
+}
+
+func (r *blockingReader) Read(p []byte) (int, error) {
+	if r.statement.Len() == 0 {
+		<-r.block
+		return 0, io.EOF
+	}
+	return r.statement.Read(p)
+}
+
+func (r *blockingReader) Close() error {
+	return nil
+}
+
+func TestTimedOutRunWarningsFile(t *testing.T) {
+	startRun()
+	setForTest(t, &runAbandoned, false)
+	setForTest(t, &errorStrategy, strategyCollect)
+	preset := builtinPresets[defaultPresetName]
+	preset.DateFormat = "02/01/2006"
+	statement := statementHeader +
+		"01/06/2020,CARD,REF1,TESCO,4.01,,1\n" +
+		"2020-06-02,CARD,REF2,TESCO,4.01,,1\n" +
+		"03/06/2020,CARD,REF3,TESCO,n/a,,1\n"
+	// The run is left blocked for good, as the tool leaves an abandoned run to the exit
+	in := input{name: "test.csv", reader: &blockingReader{statement: strings.NewReader(statement), block: make(chan struct{})}}
+	out, err := newTransactionWriter(formatCSV, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := runWithDeadline(ctx, []input{in}, &preset, ',', out, ProgressHook{}); err != context.DeadlineExceeded {
+		t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	if !runAbandoned {
+		t.Fatal("the run wasn't abandoned")
+	}
+
+	setForTest(t, &warningsPath, filepath.Join(t.TempDir(), "warnings.txt"))
+	setForTest(t, &warningsFormat, warningsGitHub)
+	saveWarnings()
+	content, err := ioutil.ReadFile(warningsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 || !strings.Contains(string(content), `Unable to parse date "2020-06-02"`) || !strings.Contains(string(content), "n/a") {
+		t.Errorf("got warnings file:\n%s\nwant the date warning and the rejected amount", content)
+	}
+	if s := finalSummary(); s.Read != 3 || s.Rejected != 1 {
+		t.Errorf("got %d read and %d rejected, want 3 and 1", s.Read, s.Rejected)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// What to do with a foreign currency transaction when there is no rate for it
+const (
+	missingRateSkip        = "skip"
+	missingRatePassThrough = "passthrough"
+)
+
+// ratePoint is an exchange rate effective from a date
+type ratePoint struct {
+	date time.Time
+	rate float64
+}
+
+// rateTable holds exchange rates by currency, each sorted by date.
+// A rate is the number of base currency units one unit of the currency buys.
+type rateTable map[string][]ratePoint
+
+// Exchange rates to convert foreign currency transactions with, nil when not wanted
+var rates rateTable
+
+// loadRates reads a CSV of date,currency,rate lines. A header line is allowed.
+func loadRates(r io.Reader) (rateTable, error) {
+	csvr := csv.NewReader(r)
+	csvr.FieldsPerRecord = 3
+	csvr.TrimLeadingSpace = true
+
+	table := rateTable{}
+	for line := 1; ; line++ {
+		row, err := csvr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		date, dateErr := parseDate(row[0], "")
+		rate, rateErr := strconv.ParseFloat(strings.TrimSpace(row[2]), 64)
+		if dateErr != nil || rateErr != nil || rate <= 0 {
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("invalid rate on line %d: %s", line, strings.Join(row, ","))
+		}
+		currency := strings.ToUpper(strings.TrimSpace(row[1]))
+		table[currency] = append(table[currency], ratePoint{date: date, rate: rate})
+	}
+
+	// Stable, so of several rates on the same date the one listed last is used
+	for _, points := range table {
+		sort.SliceStable(points, func(i, j int) bool { return points[i].date.Before(points[j].date) })
+	}
+	return table, nil
+}
+
+// lookup finds the rate for a currency on a date, falling back to the nearest earlier date
+func (r rateTable) lookup(currency string, date time.Time) (float64, bool) {
+	points := r[currency]
+	i := sort.Search(len(points), func(i int) bool { return points[i].date.After(date) })
+	if i == 0 {
+		return 0, false
+	}
+	return points[i-1].rate, true
+}
+
+// convertCurrency converts a transaction in a foreign currency into the base currency.
+// It returns false if the transaction should be skipped for want of a rate.
+func convertCurrency(t *transaction, currency string, line int) bool {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" || currency == baseCurrency || !t.hasAmount {
+		return true
+	}
+
+	rate, ok := rates.lookup(currency, t.date)
+	if !ok || t.date.IsZero() {
+		if missingRate == missingRateSkip {
+			warnRow(line, "No %s rate for %q on line %d, skipping it", currency, t.Date, line)
+			return false
+		}
+		warnRow(line, "No %s rate for %q on line %d, leaving the amount unconverted", currency, t.Date, line)
+		return true
+	}
+
+	t.originalAmount = t.Amount
+	t.originalCurrency = currency
+	t.setAmount(roundPence(float64(t.amount) * rate))
+	log.Debugf("Converted %s %s to %s %s at %g on line %d", t.originalAmount, currency, t.Amount, baseCurrency, rate, line)
+	return true
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"path/filepath"
+	"strconv"
+)
+
+// Status of a reconciled row
+const (
+	reconcileOK       = "ok"
+	reconcileMismatch = "MISMATCH"
+	// The row states no running balance, or one that can't be read
+	reconcileNoBalance = "no balance"
+)
+
+// reconciliation follows the balance of each statement through its amounts, comparing it with
+// the running balance the bank states on every row. Rows are taken in statement order, so it
+// suits statements listing the oldest transaction first.
+type reconciliation struct {
+	rows [][]string
+	// Balance computed so far for the statement being read, once started
+	balance int64
+	started bool
+	// Opening balance in pence of the first statement, from -openingbalance or -emitopeningbalance
+	opening    int64
+	hasOpening bool
+}
+
+// Row by row reconciliation of the running balance, nil when not wanted
+var reconcile *reconciliation
+
+// startStatement begins a statement, whose balance starts from the opening balance if it is the
+// first, or otherwise from the balance stated on its first row
+func (r *reconciliation) startStatement() {
+	r.started = r.hasOpening
+	r.balance = r.opening
+	r.hasOpening = false
+}
+
+// add reconciles a transaction with the running balance stated on its row
+func (r *reconciliation) add(t *transaction, balance string, name string) {
+	if !t.hasAmount {
+		return
+	}
+	stated, err := parseAmount(balance)
+	statedOK := hasValue(balance) && err == nil
+	if !r.started && statedOK {
+		r.balance, r.started = stated-t.amount, true
+	}
+	row := []string{filepath.Base(name), strconv.Itoa(t.lines[0]), t.Date, t.Reference, t.Amount, "", "", "", reconcileNoBalance}
+	if r.started {
+		r.balance += t.amount
+		row[5] = formatAmount(r.balance)
+	}
+	if statedOK && r.started {
+		row[6], row[7] = formatAmount(stated), formatAmount(stated-r.balance)
+		row[8] = reconcileOK
+		summary.Reconciled++
+		if stated != r.balance {
+			row[8] = reconcileMismatch
+			summary.BalanceMismatches++
+			log.Debugf("The balance of %s on line %d is %s, but %s is stated", name, t.lines[0], formatAmount(r.balance), formatAmount(stated))
+		}
+	}
+	r.rows = append(r.rows, row)
+}
+
+// write writes the reconciliation as CSV, a line per transaction with an amount
+func (r *reconciliation) write(w io.Writer) error {
+	csvw := csv.NewWriter(w)
+	csvw.Write([]string{"File", "Line", "Date", "Reference", "Amount", "Balance", "Stated Balance", "Difference", "Status"})
+	for _, row := range r.rows {
+		csvw.Write(row)
+	}
+	csvw.Flush()
+	return csvw.Error()
+}
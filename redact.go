@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Ways of redacting sensitive source values
+const (
+	redactBlank = "blank"
+	redactMask  = "mask"
+)
+
+// redactPatternPrefix marks a -redact entry as a regular expression rather than a column name
+const redactPatternPrefix = "re:"
+
+// redactor blanks or masks sensitive source values before they can reach any output
+type redactor struct {
+	columns  map[string]bool
+	patterns []*regexp.Regexp
+	mode     string
+}
+
+// Redactor for the current run, nil when nothing is redacted
+var redaction *redactor
+
+// newRedactor builds a redactor from column names and "re:" prefixed regular expressions
+func newRedactor(entries []string, mode string) (*redactor, error) {
+	if mode != redactBlank && mode != redactMask {
+		return nil, fmt.Errorf("unknown redaction mode %q, expected %s or %s", mode, redactBlank, redactMask)
+	}
+	r := &redactor{columns: map[string]bool{}, mode: mode}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry, redactPatternPrefix) {
+			pattern, err := regexp.Compile(strings.TrimPrefix(entry, redactPatternPrefix))
+			if err != nil {
+				return nil, fmt.Errorf("invalid redaction pattern %q: %s", entry, err)
+			}
+			r.patterns = append(r.patterns, pattern)
+			continue
+		}
+		r.columns[strings.TrimSpace(entry)] = true
+	}
+	return r, nil
+}
+
+// hide blanks or masks a value
+func (r *redactor) hide(value string) string {
+	if r.mode == redactBlank {
+		return ""
+	}
+	return strings.Map(func(c rune) rune {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			return '*'
+		}
+		return c
+	}, value)
+}
+
+// apply redacts a source row in place, returning the number of values redacted
+func (r *redactor) apply(headers []string, row []string) int {
+	count := 0
+	for i, cell := range row {
+		if i < len(headers) && r.columns[headers[i]] {
+			if cell != "" {
+				row[i] = r.hide(cell)
+				count++
+			}
+			continue
+		}
+		for _, pattern := range r.patterns {
+			redacted := pattern.ReplaceAllStringFunc(row[i], func(match string) string {
+				count++
+				return r.hide(match)
+			})
+			row[i] = redacted
+		}
+	}
+	return count
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Prefix of the patterns in a reference list that are regular expressions rather than substrings
+const refRegexPrefix = "re:"
+
+// refList is a list of patterns, each a substring or a regular expression, matched against references
+type refList struct {
+	substrings []string
+	patterns   []*regexp.Regexp
+}
+
+// References whose transactions are always dropped, nil when there are none
+var excludeRefs *refList
+
+// References whose transactions are the only ones kept, nil to keep every transaction
+var includeRefs *refList
+
+// loadRefList reads a reference list of one pattern per line, ignoring blank lines and # comments.
+// Patterns starting with "re:" are regular expressions, others substrings.
+func loadRefList(r io.Reader) (*refList, error) {
+	list := &refList{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		pattern := strings.TrimSpace(scanner.Text())
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+		if !strings.HasPrefix(pattern, refRegexPrefix) {
+			list.substrings = append(list.substrings, pattern)
+			continue
+		}
+		re, err := regexp.Compile(strings.TrimPrefix(pattern, refRegexPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern on line %d: %s", line, err)
+		}
+		list.patterns = append(list.patterns, re)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// size is the number of patterns in the list
+func (l *refList) size() int {
+	return len(l.substrings) + len(l.patterns)
+}
+
+// match reports whether a reference matches any pattern of the list
+func (l *refList) match(reference string) bool {
+	for _, s := range l.substrings {
+		if strings.Contains(reference, s) {
+			return true
+		}
+	}
+	for _, re := range l.patterns {
+		if re.MatchString(reference) {
+			return true
+		}
+	}
+	return false
+}
+
+// keepReference applies -excluderefs and -includerefs to a transaction, returning false when it is to be dropped
+func keepReference(t *transaction, line int) bool {
+	if excludeRefs != nil && excludeRefs.match(t.Reference) {
+		log.Debugf("Dropping line %d as its reference %q is excluded", line, t.Reference)
+		explainf(line, "Not written as its reference matches -excluderefs")
+		summary.ExcludedRefs++
+		return false
+	}
+	if includeRefs != nil && !includeRefs.match(t.Reference) {
+		log.Debugf("Dropping line %d as its reference %q isn't included", line, t.Reference)
+		explainf(line, "Not written as its reference doesn't match -includerefs")
+		summary.NotIncludedRefs++
+		return false
+	}
+	return true
+}
+
+// loadRefListFile reads a reference list from a file, stopping the run when it can't be read
+func loadRefListFile(path string) *refList {
+	listFile := openFile(path)
+	defer listFile.Close()
+	list, err := loadRefList(listFile)
+	if err != nil {
+		log.Fatalf("Unable to read references from %s: %s", path, err)
+	}
+	log.Debugf("%d references loaded from %s", list.size(), path)
+	return list
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// rowScriptRequest is sent to the row script for every transaction
+type rowScriptRequest struct {
+	Line      int               `json:"line"`
+	Row       map[string]string `json:"row"`
+	Transform *Transform        `json:"transform"`
+}
+
+// rowScriptResponse is read back from the row script, either a Transform or an error
+type rowScriptResponse struct {
+	Transform
+	Error string `json:"error"`
+}
+
+// rowScript pipes transactions through a single long running external program.
+// Each request is written as one line of JSON to its stdin, and it must answer each with
+// one line of JSON on stdout, flushing as it goes.
+type rowScript struct {
+	command string
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	stdout  *bufio.Reader
+	// Set once the program has stopped responding, after which the original mapping is used
+	broken bool
+}
+
+// startRowScript starts the row script program, given as a command line
+func startRowScript(command string) (*rowScript, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty row script")
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("unable to start row script %q: %s", command, err)
+	}
+
+	return &rowScript{command: command, cmd: cmd, stdin: stdin, stdout: bufio.NewReader(stdout)}, nil
+}
+
+// apply replaces the transaction's mapping with the row script's answer,
+// keeping the original mapping if the script reports an error or fails
+func (s *rowScript) apply(t *transaction, data map[string]string) {
+	if s.broken {
+		return
+	}
+	line := t.lines[0]
+
+	request, err := json.Marshal(rowScriptRequest{Line: line, Row: data, Transform: t.Transform})
+	if err != nil {
+		warnRow(line, "Row script: unable to encode line %d: %s", line, err)
+		return
+	}
+	if _, err := s.stdin.Write(append(request, '\n')); err != nil {
+		s.fail(err)
+		return
+	}
+
+	answer, err := s.stdout.ReadBytes('\n')
+	if err != nil {
+		s.fail(err)
+		return
+	}
+	var response rowScriptResponse
+	if err := json.Unmarshal(answer, &response); err != nil {
+		warnRow(line, "Row script: invalid answer for line %d, using the original mapping: %s", line, err)
+		return
+	}
+	if response.Error != "" {
+		warnRow(line, "Row script: error for line %d, using the original mapping: %s", line, response.Error)
+		return
+	}
+
+	if response.Amount != t.Amount {
+		amount, err := parseAmountWith(response.Amount, outputNumberFormat)
+		if err != nil {
+			warnRow(line, "Row script: invalid amount for line %d, using the original mapping: %s", line, err)
+			return
+		}
+		t.amount = amount
+		t.hasAmount = true
+		// Written like any other amount, so a zero is 0.00 rather than whatever the script gave
+		response.Amount = formatAmount(amount)
+	}
+	*t.Transform = response.Transform
+}
+
+// fail stops using a row script that can no longer be talked to
+func (s *rowScript) fail(err error) {
+	log.Errorf("Row script %q stopped responding, using the original mapping from now on: %s", s.command, err)
+	s.broken = true
+}
+
+// close tells the row script there are no more transactions and waits for it to finish
+func (s *rowScript) close() {
+	s.stdin.Close()
+	if err := s.cmd.Wait(); err != nil && !s.broken {
+		log.Warningf("Row script %q exited with: %s", s.command, err)
+	}
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// Identifies the current run in every log line, report and summary, so its artifacts can be found together
+var runID = newRunID()
+
+// newRunID makes a short identifier from the start time and a few random bytes, e.g. 20200601T120000-1a2b3c
+func newRunID() string {
+	random := make([]byte, 3)
+	rand.Read(random)
+	return time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(random)
+}
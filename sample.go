@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// transactionSample randomly picks the transactions to write, either a share of them or a fixed number
+type transactionSample struct {
+	// Share of transactions kept, from 0 to 1, when no count is given
+	fraction float64
+	// Number of transactions kept, zero for none
+	count int
+	rnd   *rand.Rand
+}
+
+// Random sample of the transactions to write, nil when every transaction is written
+var sample *transactionSample
+
+// parseSample reads a -sample value, either a percentage such as "10%" or a count such as "50"
+func parseSample(spec string, seed int64) (*transactionSample, error) {
+	s := &transactionSample{rnd: rand.New(rand.NewSource(seed))}
+	if strings.HasSuffix(spec, "%") {
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(spec, "%"), 64)
+		if err != nil || percent <= 0 || percent > 100 {
+			return nil, fmt.Errorf("invalid -sample %q, expected a percentage over 0 and up to 100", spec)
+		}
+		s.fraction = percent / 100
+		return s, nil
+	}
+	count, err := strconv.Atoi(spec)
+	if err != nil || count <= 0 {
+		return nil, fmt.Errorf("invalid -sample %q, expected a count or a percentage such as 10%%", spec)
+	}
+	s.count = count
+	return s, nil
+}
+
+// keep decides whether a transaction is in a percentage sample
+func (s *transactionSample) keep() bool {
+	return s.count > 0 || s.rnd.Float64() < s.fraction
+}
+
+// pick chooses the transactions of a counted sample, keeping them in their original order
+func (s *transactionSample) pick(transactions []*transaction) []*transaction {
+	if s.count == 0 || len(transactions) <= s.count {
+		return transactions
+	}
+	chosen := s.rnd.Perm(len(transactions))[:s.count]
+	sort.Ints(chosen)
+	picked := make([]*transaction, 0, s.count)
+	for _, i := range chosen {
+		picked = append(picked, transactions[i])
+	}
+	return picked
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// formulaPrefixes are the leading characters that make spreadsheets treat a cell as a formula
+const formulaPrefixes = "=+-@"
+
+// Ways of neutralising text fields that could be interpreted as spreadsheet formulas
+const (
+	sanitizeNone  = "none"
+	sanitizeQuote = "quote"
+	sanitizeStrip = "strip"
+)
+
+// validateSanitizeMode checks the -sanitizeformulas value
+func validateSanitizeMode(mode string) error {
+	switch mode {
+	case sanitizeNone, sanitizeQuote, sanitizeStrip:
+		return nil
+	}
+	return fmt.Errorf("unknown formula sanitizing mode %q, expected %s, %s or %s", mode, sanitizeNone, sanitizeQuote, sanitizeStrip)
+}
+
+// sanitizeFormula neutralises a value starting with a formula character,
+// either by prefixing a single quote or by stripping the leading formula characters
+func sanitizeFormula(value string, mode string) string {
+	if value == "" || !strings.ContainsAny(value[:1], formulaPrefixes) {
+		return value
+	}
+	switch mode {
+	case sanitizeQuote:
+		return "'" + value
+	case sanitizeStrip:
+		return strings.TrimLeft(value, formulaPrefixes)
+	}
+	return value
+}
+
+// sanitizeTransform neutralises formulas in the text fields of a transaction.
+// The Amount is left alone as a leading minus is legitimate there.
+func sanitizeTransform(t *Transform, mode string, line int) {
+	for _, field := range []*string{&t.Payee, &t.Description, &t.Reference, &t.ChequeNumber} {
+		sanitized := sanitizeFormula(*field, mode)
+		if sanitized != *field {
+			warnRow(line, "Neutralised formula-like value %q on line %d", *field, line)
+			*field = sanitized
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSanitizeFormula(t *testing.T) {
+	tests := []struct {
+		value string
+		mode  string
+		want  string
+	}{
+		{"=HYPERLINK(\"http://evil.example\")", sanitizeQuote, "'=HYPERLINK(\"http://evil.example\")"},
+		{"=HYPERLINK(\"http://evil.example\")", sanitizeStrip, "HYPERLINK(\"http://evil.example\")"},
+		{"+cmd|' /C calc'!A0", sanitizeQuote, "'+cmd|' /C calc'!A0"},
+		{"-2+3", sanitizeStrip, "2+3"},
+		{"@SUM(A1:A9)", sanitizeStrip, "SUM(A1:A9)"},
+		{"=-=+1", sanitizeStrip, "1"},
+		{"=1+1", sanitizeNone, "=1+1"},
+		{"TESCO =1", sanitizeQuote, "TESCO =1"},
+		{"", sanitizeQuote, ""},
+	}
+	for _, tt := range tests {
+		if got := sanitizeFormula(tt.value, tt.mode); got != tt.want {
+			t.Errorf("%q with %s: got %q, want %q", tt.value, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeFormulasLeavesAmounts(t *testing.T) {
+	setForTest(t, &sanitizeFormulas, sanitizeQuote)
+	statement := statementHeader +
+		"01/06/2020,=HYPERLINK(1),@REF,+44 PHONE,4.01,,1\n"
+	got := outputRows(transformStatement(t, statement, nil))
+	want := []string{"01/06/2020,-4.01,,'+44 PHONE,'=HYPERLINK(1) @REF,,Debit"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got rows %q, want %q", got, want)
+	}
+	if len(summary.Warnings) != 2 {
+		t.Errorf("got %d warnings, want 2", len(summary.Warnings))
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// selfCheckSample is a tiny statement in the default bank format used to check parsing end to end
+const selfCheckSample = `Account Name,Self Check,,,,,
+Transactions,,,,,,
+ Date,Description,Bank     Reference,Customer  Reference,Debit,Credit,Running  Balance  
+01/06/2020,FASTER PAYMENT,REF1,Invoice 1001,,150.00,1150.00
+02/06/2020,CARD PAYMENT,REF2,AMAZON UK,25.99,,1124.01
+`
+
+// selfCheck checks the log directory, the output location and the transform itself,
+// reporting whether each works
+func selfCheck() bool {
+	checks := []struct {
+		name  string
+		check func() error
+	}{
+		{"Create log directory " + logPath, func() error { return os.MkdirAll(logPath, 0777) }},
+		{"Write to output location", checkOutputLocation},
+		{"Transform sample statement", checkSampleTransform},
+	}
+
+	passed := true
+	for _, c := range checks {
+		if err := c.check(); err != nil {
+			log.Errorf("FAIL %s: %s", c.name, err)
+			passed = false
+			continue
+		}
+		log.Noticef("PASS %s", c.name)
+	}
+	return passed
+}
+
+// checkOutputLocation writes and removes a temporary file next to the output file
+func checkOutputLocation() error {
+	dir := "."
+	if csvOutputPath != "" {
+		dir = filepath.Dir(csvOutputPath)
+	}
+	fh, err := ioutil.TempFile(dir, ".selfcheck.*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(fh.Name())
+	if _, err := fh.WriteString("self check\n"); err != nil {
+		fh.Close()
+		return err
+	}
+	return fh.Close()
+}
+
+// checkSampleTransform transforms the bundled sample with the default preset and checks the result.
+// It runs this program again with only the options it needs, so the options of the real run, such as
+// filters or a date range, neither change the sample's result nor add to the real run's summary.
+func checkSampleTransform() error {
+	program, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	dir, err := ioutil.TempDir("", "xerobanktransform-selfcheck")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	cmd := exec.Command(program, "-file", "-", "-outfile", filepath.Join(dir, "sample.csv"),
+		"-logpath", dir, "-outputconsole=false", "-jsonsummary")
+	cmd.Stdin = strings.NewReader(selfCheckSample)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	answer, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	var outcome runOutcome
+	if err := json.Unmarshal(answer, &outcome); err != nil {
+		return fmt.Errorf("unreadable summary %q: %s", answer, err)
+	}
+	if outcome.Written != 2 || outcome.Credits != "150.00" || outcome.Debits != "25.99" {
+		return fmt.Errorf("unexpected result, %d transactions with credits %s and debits %s",
+			outcome.Written, outcome.Credits, outcome.Debits)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// splitTransactionWriter writes at most maxRows transactions to each of a series of numbered files,
+// such as xero.1.csv and xero.2.csv, each with its own header
+type splitTransactionWriter struct {
+	format  string
+	path    string
+	maxRows int
+	// Number of the current file, counting from 1, and the transactions written to it
+	part    int
+	rows    int
+	current transactionWriter
+}
+
+// newSplitTransactionWriter creates the first of the numbered files for an output path
+func newSplitTransactionWriter(format string, path string, maxRows int) (*splitTransactionWriter, error) {
+	w := &splitTransactionWriter{format: format, path: path, maxRows: maxRows}
+	return w, w.open()
+}
+
+// open starts the next numbered file
+func (w *splitTransactionWriter) open() error {
+	w.part++
+	w.rows = 0
+	file, err := createOutputFile(numberedPath(w.path, w.part))
+	if err != nil {
+		return err
+	}
+	writer, err := newTransactionWriter(w.format, encodeOutput(file))
+	w.current = writer
+	return err
+}
+
+func (w *splitTransactionWriter) WriteHeader() error {
+	return w.current.WriteHeader()
+}
+
+func (w *splitTransactionWriter) Write(t *transaction) error {
+	if w.rows == w.maxRows {
+		if err := w.current.Flush(); err != nil {
+			return err
+		}
+		if err := w.open(); err != nil {
+			return err
+		}
+		log.Noticef("%d rows written, continuing in %s", w.maxRows, numberedPath(w.path, w.part))
+		if err := w.current.WriteHeader(); err != nil {
+			return err
+		}
+	}
+	w.rows++
+	return w.current.Write(t)
+}
+
+func (w *splitTransactionWriter) Flush() error {
+	return w.current.Flush()
+}
+
+// numberedPath numbers an output path, so numberedPath("xero.csv", 2) is "xero.2.csv"
+func numberedPath(path string, n int) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + strconv.Itoa(n) + ext
+}
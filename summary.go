@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Summary holds the counts and problems gathered while transforming a statement
+type Summary struct {
+	// Identifier of the run, and the -environment it ran in
+	RunID       string
+	Environment string
+	// Statements read
+	Inputs []string
+	// Options given on the command line
+	Options map[string]string
+	// When the run started and finished
+	Started  time.Time
+	Finished time.Time
+	// Transactions read from the input
+	Read int
+	// Transactions written to the output
+	Written int
+	// Transactions written to each further output
+	Outputs map[string]int
+	// Transactions matching the -filter
+	Matched int
+	// Rows skipped as blank, section markers or repeated headers
+	Skipped int
+	// Rows rejected because of a problem
+	Rejected int
+	// Transactions dropped as their reference matches -excluderefs, or doesn't match -includerefs
+	ExcludedRefs    int
+	NotIncludedRefs int
+	// Transactions with a zero amount, whether kept, dropped or rejected
+	ZeroAmounts int
+	// Transactions with no amount, whether kept, dropped or rejected
+	MissingAmounts int
+	// Rows reconciled with the running balance they state, and those whose balance doesn't match
+	Reconciled        int
+	BalanceMismatches int
+	// Sensitive source values blanked or masked
+	Redactions int
+	// Amount of the opening balance row, empty when none was written
+	OpeningBalance string
+	// Totals of the written transactions, in pence
+	Credits int64
+	Debits  int64
+	// Range of dates covered by the written transactions, zero when none could be parsed
+	FirstDate time.Time
+	LastDate  time.Time
+	// Rows rejected along the way
+	Issues []Issue
+	// Warnings about rows that were still written
+	Warnings []Issue
+}
+
+// Outcome of the current run
+var summary Summary
+
+// addWritten includes a written transaction in the totals and date range
+func (s *Summary) addWritten(t *transaction) {
+	s.Written++
+	if t.hasAmount {
+		if t.amount < 0 {
+			s.Debits -= t.amount
+		} else {
+			s.Credits += t.amount
+		}
+	}
+	if !t.date.IsZero() {
+		if s.FirstDate.IsZero() || t.date.Before(s.FirstDate) {
+			s.FirstDate = t.date
+		}
+		if s.LastDate.IsZero() || t.date.After(s.LastDate) {
+			s.LastDate = t.date
+		}
+	}
+}
+
+// writeReport writes the summary as a markdown report
+func (s *Summary) writeReport(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Bank statement transform report\n\n")
+	fmt.Fprintf(&b, "- Run: %s\n", s.RunID)
+	if s.Environment != "" {
+		fmt.Fprintf(&b, "- Environment: %s\n", s.Environment)
+	}
+	fmt.Fprintf(&b, "- Started: %s\n", s.Started.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "- Finished: %s\n", s.Finished.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "- Duration: %s\n", s.Finished.Sub(s.Started).Round(time.Millisecond))
+
+	fmt.Fprintf(&b, "\n## Inputs\n\n")
+	for _, name := range s.Inputs {
+		fmt.Fprintf(&b, "- %s\n", name)
+	}
+
+	fmt.Fprintf(&b, "\n## Options\n\n")
+	var names []string
+	for name := range s.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		fmt.Fprintf(&b, "Defaults only\n")
+	}
+	for _, name := range names {
+		fmt.Fprintf(&b, "- -%s=%s\n", name, s.Options[name])
+	}
+
+	fmt.Fprintf(&b, "\n## Counts\n\n")
+	fmt.Fprintf(&b, "| Read | Written | Skipped | Rejected | Zero amount | Redactions |\n|---|---|---|---|---|---|\n")
+	fmt.Fprintf(&b, "| %d | %d | %d | %d | %d | %d |\n", s.Read, s.Written, s.Skipped, s.Rejected, s.ZeroAmounts, s.Redactions)
+	if s.MissingAmounts > 0 {
+		fmt.Fprintf(&b, "\n- No amount: %d\n", s.MissingAmounts)
+	}
+	if s.Reconciled > 0 {
+		fmt.Fprintf(&b, "\n- Reconciled with the stated balance: %d, of which %d don't match\n", s.Reconciled, s.BalanceMismatches)
+	}
+	if s.ExcludedRefs > 0 || s.NotIncludedRefs > 0 {
+		fmt.Fprintf(&b, "\n- Dropped by -excluderefs: %d\n", s.ExcludedRefs)
+		fmt.Fprintf(&b, "- Dropped by -includerefs: %d\n", s.NotIncludedRefs)
+	}
+	if len(s.Outputs) > 0 {
+		fmt.Fprintf(&b, "\n## Further outputs\n\n")
+		var paths []string
+		for path := range s.Outputs {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			fmt.Fprintf(&b, "- %s: %d written\n", path, s.Outputs[path])
+		}
+	}
+
+	fmt.Fprintf(&b, "\n## Totals\n\n")
+	if s.OpeningBalance != "" {
+		fmt.Fprintf(&b, "- Opening balance: %s\n", s.OpeningBalance)
+	}
+	fmt.Fprintf(&b, "- Credits: %s\n", formatAmount(s.Credits))
+	fmt.Fprintf(&b, "- Debits: %s\n", formatAmount(s.Debits))
+	fmt.Fprintf(&b, "- Net: %s\n", formatAmount(s.Credits-s.Debits))
+	if s.FirstDate.IsZero() {
+		fmt.Fprintf(&b, "- Dates: unknown\n")
+	} else {
+		fmt.Fprintf(&b, "- Dates: %s to %s\n", s.FirstDate.Format("2006-01-02"), s.LastDate.Format("2006-01-02"))
+	}
+
+	fmt.Fprintf(&b, "\n## Rejected rows\n\n")
+	writeIssueList(&b, s.Issues)
+	fmt.Fprintf(&b, "\n## Warnings\n\n")
+	writeIssueList(&b, s.Warnings)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeIssueList writes issues as a markdown list
+func writeIssueList(b *strings.Builder, issues []Issue) {
+	if len(issues) == 0 {
+		fmt.Fprintf(b, "None\n")
+	}
+	for _, issue := range issues {
+		fmt.Fprintf(b, "- line %d: %s\n", issue.Line, issue.Reason)
+	}
+}
+
+// runOutcome is the outcome of a run as printed by -jsonsummary
+type runOutcome struct {
+	// "ok", "interrupted", "timeout" or "failed"
+	Status string `json:"status"`
+	RunID  string `json:"runId"`
+	// The -environment, empty when not given
+	Environment string `json:"environment,omitempty"`
+	// Why the run failed, empty when it didn't
+	Error string `json:"error,omitempty"`
+	// Categories of the errors met: "empty_input", "no_header", "missing_columns", "bad_row" or "io"
+	ErrorCategories   []string       `json:"errorCategories"`
+	Inputs            []string       `json:"inputs"`
+	Outputs           []string       `json:"outputs"`
+	Read              int            `json:"read"`
+	Written           int            `json:"written"`
+	Skipped           int            `json:"skipped"`
+	Rejected          int            `json:"rejected"`
+	ZeroAmounts       int            `json:"zeroAmounts"`
+	MissingAmounts    int            `json:"missingAmounts"`
+	Reconciled        int            `json:"reconciled,omitempty"`
+	BalanceMismatches int            `json:"balanceMismatches,omitempty"`
+	ExcludedRefs      int            `json:"excludedRefs"`
+	NotIncludedRefs   int            `json:"notIncludedRefs"`
+	Redactions        int            `json:"redactions"`
+	Warnings          int            `json:"warnings"`
+	FurtherOutputs    map[string]int `json:"furtherOutputs,omitempty"`
+	OpeningBalance    string         `json:"openingBalance,omitempty"`
+	Credits           string         `json:"credits"`
+	Debits            string         `json:"debits"`
+	Net               string         `json:"net"`
+	FirstDate         string         `json:"firstDate,omitempty"`
+	LastDate          string         `json:"lastDate,omitempty"`
+	DurationSeconds   float64        `json:"durationSeconds"`
+}
+
+// writeJSON writes the outcome of the run as a JSON object
+func (s *Summary) writeJSON(w io.Writer, status string, runErr error) error {
+	outcome := runOutcome{
+		Status:            status,
+		RunID:             s.RunID,
+		Environment:       s.Environment,
+		ErrorCategories:   []string{},
+		Inputs:            s.Inputs,
+		Outputs:           outputPaths,
+		Read:              s.Read,
+		Written:           s.Written,
+		Skipped:           s.Skipped,
+		Rejected:          s.Rejected,
+		ZeroAmounts:       s.ZeroAmounts,
+		MissingAmounts:    s.MissingAmounts,
+		Reconciled:        s.Reconciled,
+		BalanceMismatches: s.BalanceMismatches,
+		ExcludedRefs:      s.ExcludedRefs,
+		NotIncludedRefs:   s.NotIncludedRefs,
+		Redactions:        s.Redactions,
+		Warnings:          len(s.Warnings),
+		FurtherOutputs:    s.Outputs,
+		OpeningBalance:    s.OpeningBalance,
+		Credits:           formatAmount(s.Credits),
+		Debits:            formatAmount(s.Debits),
+		Net:               formatAmount(s.Credits - s.Debits),
+		DurationSeconds:   time.Since(s.Started).Seconds(),
+	}
+	if !s.FirstDate.IsZero() {
+		outcome.FirstDate = s.FirstDate.Format("2006-01-02")
+		outcome.LastDate = s.LastDate.Format("2006-01-02")
+	}
+	if runErr != nil {
+		outcome.Error = runErr.Error()
+	}
+	categories := []struct {
+		name string
+		err  error
+	}{{"empty_input", ErrEmptyInput}, {"no_header", ErrNoHeader}, {"missing_columns", ErrMissingColumns}, {"bad_row", ErrBadRow}, {"io", ErrIO}}
+	for _, c := range categories {
+		if errors.Is(runErr, c.err) || (c.err == ErrBadRow && s.Rejected > 0) {
+			outcome.ErrorCategories = append(outcome.ErrorCategories, c.name)
+		}
+	}
+	if outcome.Inputs == nil {
+		outcome.Inputs = []string{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(outcome)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Cases the text output fields can be normalised to
+const (
+	caseNone  = "none"
+	caseUpper = "upper"
+	caseLower = "lower"
+	caseTitle = "title"
+)
+
+// validateTextCase checks the -textcase value
+func validateTextCase(textCase string) error {
+	switch textCase {
+	case caseNone, caseUpper, caseLower, caseTitle:
+		return nil
+	}
+	return fmt.Errorf("unknown text case %q, expected %s, %s, %s or %s", textCase, caseNone, caseUpper, caseLower, caseTitle)
+}
+
+// changeCase converts a value to the given case
+func changeCase(value string, textCase string) string {
+	switch textCase {
+	case caseUpper:
+		return strings.ToUpper(value)
+	case caseLower:
+		return strings.ToLower(value)
+	case caseTitle:
+		return titleCase(value)
+	}
+	return value
+}
+
+// titleCase capitalises the first letter of every word and lower cases the rest.
+// A word starts at a letter that doesn't follow another letter, digit or apostrophe,
+// so "o'neil" stays one word while "smith-jones" and "a/b" are two.
+func titleCase(value string) string {
+	var b strings.Builder
+	previous := ' '
+	for _, r := range value {
+		if unicode.IsLetter(r) && !(unicode.IsLetter(previous) || unicode.IsDigit(previous) || previous == '\'') {
+			b.WriteRune(unicode.ToTitle(r))
+		} else {
+			b.WriteRune(unicode.ToLower(r))
+		}
+		previous = r
+	}
+	return b.String()
+}
+
+// changeTransformCase normalises the case of the text fields of a transaction, leaving amounts and dates alone
+func changeTransformCase(t *Transform, textCase string) {
+	t.Payee = changeCase(t.Payee, textCase)
+	t.Description = changeCase(t.Description, textCase)
+	t.Reference = changeCase(t.Reference, textCase)
+}
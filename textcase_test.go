@@ -0,0 +1,40 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChangeCase(t *testing.T) {
+	tests := []struct {
+		value    string
+		textCase string
+		want     string
+	}{
+		{"tEsCo STORES 2041", caseNone, "tEsCo STORES 2041"},
+		{"tEsCo STORES 2041", caseUpper, "TESCO STORES 2041"},
+		{"tEsCo STORES 2041", caseLower, "tesco stores 2041"},
+		{"tEsCo STORES 2041", caseTitle, "Tesco Stores 2041"},
+		{"o'NEIL smith-JONES", caseTitle, "O'neil Smith-Jones"},
+		{"amazon.co.uk/PAY", caseTitle, "Amazon.Co.Uk/Pay"},
+		{"3RD floor", caseTitle, "3rd Floor"},
+		{"émile ZOLA", caseTitle, "Émile Zola"},
+		{"", caseTitle, ""},
+	}
+	for _, tt := range tests {
+		if got := changeCase(tt.value, tt.textCase); got != tt.want {
+			t.Errorf("%q in %s case: got %q, want %q", tt.value, tt.textCase, got, tt.want)
+		}
+	}
+}
+
+func TestTextCaseLeavesDatesAndAmounts(t *testing.T) {
+	setForTest(t, &textCase, caseTitle)
+	statement := statementHeader +
+		"01/06/2020,CARD PAYMENT,REF1,tesco STORES,4.01,,1\n"
+	got := outputRows(transformStatement(t, statement, nil))
+	want := []string{"01/06/2020,-4.01,,Tesco Stores,Card Payment Ref1,,Debit"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got rows %q, want %q", got, want)
+	}
+}
@@ -1,13 +1,20 @@
 package main
 
 import (
+	"context"
 	"encoding/csv"
+	"errors"
 	"flag"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"os/signal"
 	"os/user"
 	"strings"
+	"syscall"
 	"time"
+	"unicode"
 
 	logging "github.com/op/go-logging"
 	"github.com/stretchr/slog"
@@ -29,57 +36,670 @@ type Transform struct {
 
 var (
 	// Logger settings
-	log              = logging.MustGetLogger("xero-bank-transform")
-	logConsoleFormat = logging.MustStringFormatter(
-		`%{color}%{time:15:04:05.000} %{shortfunc} (%{shortfile}) >> %{message} %{color:reset}`,
-	)
-	logFileFormat = logging.MustStringFormatter(
-		`%{time:15:04:05.000} %{shortfunc} (%{shortfile}) >> %{message}`,
-	)
+	log                             = logging.MustGetLogger("xero-bank-transform")
+	logConsoleFormat, logFileFormat = logFormats(runID)
 
 	// Path to log files
 	logPath string
+	// Free text naming where the run's output is headed, such as sandbox or production
+	environment string
+	// Charset of the output, and what to do with characters it lacks
+	outputEncoding string
+	unencodable    string
 	// Enable console log
 	outputConsole bool
 	// CSV file to import
 	csvImportPath string
 	// CSV file to output
 	csvOutputPath string
+	// Transform field used to merge split transactions on the same day
+	coalesceBy string
+	// Name of the bank preset describing the import file
+	bankName string
+	// Directory holding additional bank presets
+	presetDir string
+	// Preset settings given on the command line, overriding the selected preset
+	flagPreset Preset
+	// Go time layout of dates written to the output
+	outputDateFormat string
+	// Language of month and weekday names in dates
+	dateLocaleSpec string
+	// How to neutralise text fields that look like spreadsheet formulas
+	sanitizeFormulas string
+
+	// How to deal with rows that can't be transformed
+	errorStrategy string
+	// CSV file to write rejected rows into
+	rejectPath string
+	// Password for encrypted ZIP archives
+	zipPassword string
+	// Read every CSV file under a directory given as -file
+	recurse bool
+	// Case to normalise text output fields to
+	textCase string
+	// CSV file to write per-day totals into
+	dailyReportPath string
+	// CSV file to write net amounts by category and month into, and the keyword rules giving the categories
+	pivotReportPath string
+	reconcilePath   string
+	categoriesPath  string
+	// Maximum field lengths overriding the Xero limits
+	maxLengthsSpec string
+	// Whether truncated fields end with an ellipsis
+	truncateEllipsis bool
+	// Maximum length of each output field
+	maxLengths map[string]int
+	// External program every transaction is piped through
+	rowScriptCommand string
+	// Running row script, nil when not wanted
+	script *rowScript
+	// Locale deciding the separators of amounts
+	numberLocale string
+	// Write transactions in the reverse of the order they were read
+	reverseOutput bool
+	// Transform files that look like they are already in the Xero format
+	force bool
+	// Markdown file to write a report of the run into
+	reportPath string
+	// Previous CSV output to list the changes against, and the file to list them in, stdout when empty
+	diffAgainstPath string
+	diffOutPath     string
+	// Format of the output file, or comma separated formats
+	outputFormat string
+	// Formats to write and the file each is written to
+	outputFormats []string
+	outputPaths   []string
+	// JSON layout of fixed width output
+	fixedLayoutPath string
+	// JSON, YAML or TOML config file to use instead of a bank preset
+	configPath string
+	// File to write a config skeleton for the import file into
+	generateConfigPath string
+	// Source columns and patterns to redact
+	redactEntries repeatedList
+	// Whether redacted values are blanked or masked
+	redactMode string
+	// Further outfile=config pairs mapped from the same read of the input
+	alsoOutputs repeatedList
+	// Join description rows without a date or amount onto the transaction before them
+	joinContinuations bool
+	// Drop the spaces padding the start of statement cells as they are read
+	trimLeadingSpace bool
+	// Transaction Type labels of credits and debits
+	creditType string
+	debitType  string
+	// Expression selecting the transactions to write
+	filterSpec string
+	// Opening balance of the account, and the date of a row written for it
+	openingBalanceAmount   string
+	openingBalanceSpec     string
+	openingBalanceInTotals bool
+	// File keeping the latest date processed, so later runs skip what's already done
+	watermarkPath string
+	// Dates of the first and last transactions written, or the number of days up to -asof to write
+	fromSpec string
+	toSpec   string
+	lastDays int
+	asOfSpec string
+	// Share or number of transactions to randomly pick for writing
+	sampleSpec string
+	// Seed of the random sample, from the clock when zero
+	sampleSeed int64
+	// Stop on suspicious data rather than warning about it
+	strict bool
+	// Go time layout of the timestamp in log file names and output paths
+	timestampFormat string
+	// Prepend an index column numbering the written transactions
+	includeIndex bool
+	// Headers sent when downloading a -file URL
+	fetchHeaders repeatedList
+	// Longest a -file URL download may take
+	fetchTimeout time.Duration
+	// What an empty Payee falls back to
+	payeeFallbackSpec string
+	// CSV of pattern,payee aliases unifying payee names
+	payeeAliasesPath string
+	// Files of references whose transactions are dropped, or the only ones kept
+	excludeRefsPath string
+	includeRefsPath string
+	// Config file to check instead of transforming
+	validateConfigPath string
+	// Only count the transactions instead of transforming them
+	countOnly bool
+	// Only print the column names of the import file
+	listColumnsOnly bool
+	// Print the outcome of the run as JSON on stdout
+	jsonSummary bool
+	// Most transactions written to each output file, no limit when zero
+	maxRows int
+	// Time zone of dates given as Unix timestamps
+	timestampZone string
+	// Check the environment instead of transforming
+	runSelfCheck bool
+	// Longest the run may take, no limit when zero
+	timeout time.Duration
+	// What to do with the partial output after a timeout
+	onTimeout string
+	// CSV file of exchange rates
+	ratesPath string
+	// Currency amounts are converted into
+	baseCurrency string
+	// Append the base name of the statement each transaction came from
+	includeSource bool
+	// Append an identifier hashed from each transaction, the same on every run
+	includeFITID bool
+	// Keep the debit and credit cells as found in the source in extra columns
+	keepRawAmounts bool
+	// Keep the amount from before currency conversion in extra columns
+	keepOriginal bool
+	// What to do with a foreign currency transaction without a rate
+	missingRate string
+	// Separators of amounts when no locale is given
+	thousandsSeparator string
+	decimalSeparator   string
 
 	// file to write console output into
 	consoleLogFile *os.File
+)
+
+// xeroCSVHeaders is the header row of the Xero import format
+var xeroCSVHeaders = []string{
+	"*Date",
+	"*Amount",
+	"Payee",
+	"Description",
+	"Reference",
+	"Cheque Number",
+	"Transaction Type",
+}
 
-	csvTransactionsTotal int
+// Exit codes returned for failures that callers may want to tell apart
+const (
+	exitEmptyInput  = 3
+	exitCheckFailed = 4
+	exitTimeout     = 124
+	exitInterrupted = 130
 )
 
+// logFormats makes the console and log file formats, stamping every line with a tag naming the run
+func logFormats(tag string) (logging.Formatter, logging.Formatter) {
+	return logging.MustStringFormatter(`%{color}%{time:15:04:05.000} ` + tag + ` %{shortfunc} (%{shortfile}) >> %{message} %{color:reset}`),
+		logging.MustStringFormatter(`%{time:15:04:05.000} ` + tag + ` %{shortfunc} (%{shortfile}) >> %{message}`)
+}
+
+// defineFlags defines the command line flags, setting every option to its default
+func defineFlags(fs *flag.FlagSet) {
+	fs.StringVar(&csvImportPath, "file", "", "CSV file (or ZIP archive of CSV files, or http(s) URL of a CSV file, or - for stdin) to read from")
+	fs.StringVar(&outputEncoding, "outputencoding", "utf-8", "Charset the output is encoded in, e.g. windows-1252")
+	fs.StringVar(&unencodable, "unencodable", unencodableFail, "Characters the -outputencoding can't represent either \"fail\" the run or are \"replace\"d")
+	fs.StringVar(&csvOutputPath, "outfile", "", "File to output to, or comma separated files for several -format, stdout when empty")
+	fs.BoolVar(&mkdirOut, "mkdirout", true, "Create missing parent directories of output files, or fail before transforming anything if false")
+	fs.StringVar(&logPath, "logpath", "~/logs/xero-bank-transform", "Path to console log files")
+	fs.BoolVar(&outputConsole, "outputconsole", true, "Enable console log")
+	fs.StringVar(&coalesceBy, "coalesceby", "", "Merge same-day transactions sharing this field (e.g. Reference) by summing amounts")
+	fs.StringVar(&bankName, "bank", defaultPresetName, "Bank preset describing the import file")
+	fs.StringVar(&presetDir, "presetdir", "", "Directory of additional bank presets as JSON, YAML or TOML files")
+	fs.StringVar(&configPath, "config", "", "JSON, YAML or TOML config file describing the import file, used instead of -bank")
+	fs.StringVar(&generateConfigPath, "generateconfig", "", "Write a JSON config skeleton listing the columns of the import file to this file, then exit")
+	fs.StringVar(&flagPreset.Delimiter, "delimiter", ",", "Field delimiter of the import file (\"tab\" for tabs)")
+	fs.StringVar(&flagPreset.DateFormat, "dateformat", "", "Go time layout of dates in the import file, \"unix\" or \"unixmilli\" for timestamps, empty to leave dates unchanged")
+	fs.StringVar(&timestampZone, "tz", "UTC", "Time zone, e.g. Europe/London, giving the dates of \"unix\" and \"unixmilli\" timestamps")
+	fs.Var((*stringList)(&flagPreset.HeaderSignature), "headersignature", "Comma separated leading cells identifying the header row")
+	fs.Var((*stringList)(&flagPreset.SectionMarkers), "sectionmarkers", "Comma separated words marking the start of the transactions section")
+	fs.StringVar(&unnamedColumnPrefix, "unnamedprefix", unnamedColumnPrefix, "Columns with an empty header are named this followed by their position, as in col1, so mappings can refer to them")
+	fs.StringVar(&missingColumnsPolicy, "missingcolumns", missingColumnsPolicy, "When date or amount columns of the mapping aren't in the header, \"fail\" before reading any row or \"warn\" and carry on")
+	fs.StringVar(&uniformColumnsPolicy, "requireuniformcolumns", "", "When a data row has a different number of cells from its header, \"fail\" or \"warn\" about the first such row (off by default)")
+	fs.IntVar(&headerRows, "headerrows", headerRows, "Rows the header spans, the labels of each column joined across them, as in \"Running\" over \"Balance\"")
+	fs.IntVar(&maxHeaderScan, "maxheaderscan", maxHeaderScan, "Give up looking for the header after this many rows (0 for no limit)")
+	fs.BoolVar(&flagPreset.SkipToMarker, "skiptomarker", false, "Ignore everything before the first section marker")
+	fs.Var((*stringList)(&flagPreset.EndMarkers), "endmarkers", "Comma separated words starting the footer after the transactions, where reading stops")
+	fs.StringVar(&flagPreset.Columns.Date, "datecolumn", "", "Source column for the Date")
+	fs.StringVar(&flagPreset.Columns.Debit, "debitcolumn", "", "Source column for debit amounts")
+	fs.StringVar(&flagPreset.Columns.Credit, "creditcolumn", "", "Source column for credit amounts")
+	fs.StringVar(&flagPreset.Columns.Amount, "amountcolumn", "", "Source column for signed amounts, or unsigned ones with -indicatorcolumn")
+	fs.StringVar(&flagPreset.Columns.Indicator, "indicatorcolumn", "", "Source column saying whether the -amountcolumn value is a debit or a credit")
+	fs.Var((*stringList)(&flagPreset.DebitIndicators), "debitindicator", "Comma separated -indicatorcolumn values marking debits (default D,DR,Debit)")
+	fs.Var((*stringList)(&flagPreset.CreditIndicators), "creditindicator", "Comma separated -indicatorcolumn values marking credits (default C,CR,Credit)")
+	fs.Var((*stringList)(&flagPreset.AmountStrategies), "amountstrategies", "Comma separated ways of reading amounts to try in order, the first giving a valid amount being used for each file: separate, signed, suffix, indicator or direction")
+	fs.StringVar(&flagPreset.Columns.Direction, "directioncolumn", "", "Source column saying whether the -amountcolumn value is a debit, e.g. IsDebit")
+	fs.Var((*stringList)(&flagPreset.DirectionDebit), "directiondebit", "Comma separated -directioncolumn values marking debits (default Y,Yes,true,1)")
+	fs.Var((*stringList)(&flagPreset.DirectionCredit), "directioncredit", "Comma separated -directioncolumn values marking credits (default N,No,false,0)")
+	fs.Var((*stringList)(&flagPreset.Columns.Payee), "payeecolumns", "Comma separated source columns for the Payee, each possibly \"|\" separated alternatives of which the first with a value is used")
+	fs.Var((*stringList)(&flagPreset.Columns.Description), "descriptioncolumns", "Comma separated source columns for the Description, each possibly \"|\" separated alternatives of which the first with a value is used")
+	fs.Var((*stringList)(&flagPreset.Columns.Reference), "referencecolumns", "Comma separated source columns for the Reference, each possibly \"|\" separated alternatives of which the first with a value is used")
+	fs.Var((*stringList)(&flagPreset.Columns.ChequeNumber), "chequecolumns", "Comma separated source columns for the Cheque Number, each possibly \"|\" separated alternatives of which the first with a value is used")
+	fs.StringVar(&dateLocaleSpec, "datelocale", "", "Language of month and weekday names in dates, such as fr or de, English being understood too")
+	fs.BoolVar(&localizeOutputDates, "localizeoutputdates", false, "Write month and weekday names in the output in the -datelocale language too")
+	fs.StringVar(&outputDateFormat, "outdateformat", "02/01/2006", "Go time layout of dates in the output, used when -dateformat is set")
+	fs.StringVar(&sanitizeFormulas, "sanitizeformulas", sanitizeNone, "Neutralise text fields starting with = + - @: \"none\", \"quote\" or \"strip\"")
+	fs.StringVar(&errorStrategy, "errorstrategy", strategyFailFast, "On a bad row either stop (\"failfast\") or reject it and carry on (\"collect\")")
+	fs.StringVar(&rejectPath, "rejectfile", "", "CSV file to write rejected rows into")
+	fs.BoolVar(&showRejects, "showrejects", false, "Print each rejected row and why to stderr as it happens")
+	fs.IntVar(&maxRejectsShown, "maxrejectsshown", 20, "Most rejected rows -showrejects prints (0 for no limit)")
+	fs.BoolVar(&recurse, "recurse", false, "Transform every CSV file in a directory given as -file, and in its subdirectories")
+	fs.StringVar(&latest, "latest", "", "Transform only the newest CSV file in a directory given as -file, by its \"modified\" time or the date \"named\" in it")
+	fs.StringVar(&zipPassword, "zippassword", "", "Password for an encrypted ZIP -file")
+	fs.StringVar(&textCase, "textcase", caseNone, "Case of the Payee, Description and Reference: \"none\", \"upper\", \"lower\" or \"title\"")
+	fs.StringVar(&reconcilePath, "reconcileout", "", "CSV file to write each transaction's computed balance into, next to the balance stated on its row and their difference")
+	fs.StringVar(&pivotReportPath, "pivotreport", "", "CSV file to write net amounts into, a row per category and a column per month")
+	fs.StringVar(&categoriesPath, "categories", "", "CSV of keyword,category lines categorising transactions whose Payee, Description or Reference holds the keyword, for -pivotreport")
+	fs.StringVar(&dailyReportPath, "dailyreport", "", "CSV file to write per-day transaction counts and totals into")
+	fs.StringVar(&maxLengthsSpec, "maxlengths", "", "Comma separated Field=length limits overriding Xero's (0 disables), e.g. Reference=100")
+	fs.BoolVar(&truncateEllipsis, "truncateellipsis", false, "End truncated fields with an ellipsis")
+	fs.StringVar(&rowScriptCommand, "rowscript", "", "Program to pipe each row through as JSON lines, answering with a Transform as JSON")
+	fs.StringVar(&numberLocale, "locale", "", "Locale of amounts, e.g. \"de-DE\", overriding -thousandsep and -decimalsep")
+	fs.Var(&currencySymbols, "currencysymbols", "Comma separated currency symbols stripped from amounts")
+	fs.Int64Var(&minorUnits, "minorunits", 0, "Source amounts are whole numbers of minor units, this many to the unit, e.g. 100 for pence (none by default)")
+	fs.StringVar(&roundingMode, "roundingmode", roundNone, "Rounding of amounts with more than two decimal places: \"none\" rejects them, \"halfup\", \"halfeven\" or \"down\"")
+	fs.StringVar(&thousandsSeparator, "thousandsep", ",", "Digit grouping separator of amounts when no -locale is given, \"space\" for a regular or non-breaking space")
+	fs.StringVar(&decimalSeparator, "decimalsep", ".", "Decimal separator of amounts when no -locale is given")
+	fs.BoolVar(&reverseOutput, "reverse", false, "Write transactions in reverse order (holds every transaction in memory until the input is read)")
+	fs.Var(&groupByType, "groupbytype", "Comma separated transaction types to group the output by in order, e.g. Credit,Debit, dated earliest first within each (holds every transaction in memory until the input is read)")
+	fs.BoolVar(&groupSeparator, "groupseparator", false, "Write a blank line between the -groupbytype groups of CSV output, which Xero won't import")
+	fs.BoolVar(&force, "force", false, "Transform the file even if it already looks like a Xero import file")
+	fs.StringVar(&diffAgainstPath, "diffagainst", "", "Previous CSV output to list the rows added, removed and changed against, matching rows by date and reference")
+	fs.StringVar(&diffOutPath, "diffout", "", "CSV file to list the -diffagainst changes in, stdout when empty")
+	fs.StringVar(&warningsPath, "warningsfile", "", "File to write each rejected row and warning into as a line giving its file, line, severity and message")
+	fs.StringVar(&warningsFormat, "warningsformat", warningsGitHub, "Format of the -warningsfile: \"github\" for GitHub Actions annotations or \"json\" for a JSON object per line")
+	fs.StringVar(&reportPath, "reportfile", "", "Markdown file to write a report of the run into")
+	fs.StringVar(&outputFormat, "format", formatCSV, "Output format: \"csv\" for Xero, \"qif\", \"fixed\" width or \"json\" lines; several comma separated formats need as many -outfile names, or {format} in it")
+	fs.StringVar(&fixedLayoutPath, "fixedlayout", "", "JSON list of {field, start, width} placing each field in -format fixed lines")
+	fs.StringVar(&flagPreset.Columns.Currency, "currencycolumn", "", "Source column for the currency of each transaction")
+	fs.StringVar(&flagPreset.Columns.Balance, "balancecolumn", "", "Source column for the running balance, used to check the sign of amounts")
+	fs.StringVar(&ratesPath, "rates", "", "CSV of date,currency,rate exchange rates, a rate being the -basecurrency units one unit of currency buys")
+	fs.StringVar(&baseCurrency, "basecurrency", "GBP", "Currency to convert amounts into when -rates is given")
+	fs.BoolVar(&includeSource, "includesource", false, "Append a Source column with the base name of the statement each transaction came from")
+	fs.BoolVar(&includeFITID, "includefitid", false, "Append a FITID column identifying each transaction by a hash of its date, amount, reference and position, the same on every run")
+	fs.BoolVar(&keepRawAmounts, "keeprawamounts", false, "Keep the debit and credit cells as found in the source in extra Raw Debit and Raw Credit columns")
+	fs.BoolVar(&keepOriginal, "keeporiginal", false, "Keep the amount and currency from before conversion in extra columns")
+	fs.StringVar(&missingRate, "missingrate", missingRatePassThrough, "Foreign currency transactions without a rate are either \"skip\"ped or \"passthrough\" unconverted")
+	fs.Var(&redactEntries, "redact", "Source column, or \"re:\" prefixed regular expression, to redact from all output (may be repeated)")
+	fs.StringVar(&redactMode, "redactmode", redactMask, "Redacted values are either \"blank\"ed or \"mask\"ed with *")
+	fs.Var(&alsoOutputs, "also", "Further outfile=config pair writing the input mapped with another config (may be repeated)")
+	fs.IntVar(&progressEvery, "progress", 0, "Log progress every this many source rows (never by default)")
+	fs.DurationVar(&timeout, "timeout", 0, "Abort the run if it takes longer than this, e.g. 5m (no limit by default)")
+	fs.StringVar(&zeroPolicy, "zeropolicy", zeroKeep, "Transactions with a zero amount are either kept, \"drop\"ped or \"reject\"ed")
+	fs.StringVar(&missingAmountPolicy, "missingamountpolicy", zeroKeep, "Transactions with no amount are either kept with a blank amount, \"drop\"ped or \"reject\"ed")
+	fs.StringVar(&onTimeout, "ontimeout", onTimeoutKeep, "After a timeout either \"keep\" the transactions written so far or \"discard\" the output")
+	fs.IntVar(&maxFieldSize, "maxfieldsize", maxFieldSize, "Largest statement cell in bytes; rows holding a larger one are skipped with a warning (0 for no limit)")
+	fs.BoolVar(&trimLeadingSpace, "trimleadingspace", false, "Drop leading spaces from every statement cell as it is read, such as padded Description cells; header detection and column names ignore surrounding spaces either way")
+	fs.BoolVar(&joinContinuations, "joincontinuations", false, "Append the text of rows without a date or amount to the Reference of the transaction before")
+	fs.StringVar(&amountLayout, "amountlayout", amountLayout, "Amount columns of CSV output: \"signed\" for one Amount column, or \"spentreceived\" for Spent and Received columns")
+	fs.StringVar(&creditType, "credittype", "Credit", "Transaction Type written for credits")
+	fs.StringVar(&debitType, "debittype", "Debit", "Transaction Type written for debits")
+	fs.StringVar(&openingBalanceAmount, "openingbalance", "", "Opening balance of the account, written by -emitopeningbalance and starting the -reconcileout balance")
+	fs.StringVar(&openingBalanceSpec, "emitopeningbalance", "", "Write an Opening Balance row ahead of the transactions, dated this date; \"date,amount\" gives the amount instead of -openingbalance")
+	fs.BoolVar(&openingBalanceInTotals, "openingbalanceintotals", false, "Count the opening balance row in the written transactions and totals, which leave it out by default")
+	fs.StringVar(&filterSpec, "filter", "", "Only write transactions matching this expression, e.g. \"Amount < 0 && Reference contains 'FEE'\"")
+	fs.StringVar(&fromSpec, "from", "", "Only write transactions dated on or after this date, e.g. 2024-01-31")
+	fs.StringVar(&toSpec, "to", "", "Only write transactions dated on or before this date")
+	fs.IntVar(&lastDays, "lastdays", 0, "Only write transactions dated within this many days up to and including -asof")
+	fs.StringVar(&asOfSpec, "asof", "", "Date -lastdays counts back from, today when empty")
+	fs.StringVar(&watermarkPath, "watermarkfile", "", "File keeping the latest transaction date written; transactions at or before it are skipped and it's updated after a successful run")
+	fs.StringVar(&sampleSpec, "sample", "", "Only write a random sample of the transactions, either a percentage such as 10% or a count")
+	fs.Int64Var(&sampleSeed, "seed", 0, "Seed making -sample pick the same transactions every time (random when 0)")
+	fs.BoolVar(&strict, "strict", false, "Stop on suspicious data, such as dates switching between day and month first, instead of warning")
+	fs.StringVar(&timestampFormat, "timestampformat", "2006-01-02T15-04-05Z", "Go time layout of the timestamp in log file names, and replacing {timestamp} in output file names")
+	fs.BoolVar(&includeIndex, "includeindex", false, "Prepend an Index column numbering the transactions, which Xero doesn't expect")
+	fs.StringVar(&indexOrder, "indexorder", indexOutput, "Number -includeindex rows in \"output\" order or in \"source\" order, before sorting or reversing")
+	fs.Var(&fetchHeaders, "header", "\"Name: value\" header sent when -file is a URL, e.g. for authorisation (may be repeated)")
+	fs.DurationVar(&fetchTimeout, "fetchtimeout", time.Minute, "Longest downloading a -file URL may take")
+	fs.StringVar(&excludeRefsPath, "excluderefs", "", "File of references, one per line, whose transactions are dropped; each a substring, or a regular expression after \"re:\"")
+	fs.StringVar(&includeRefsPath, "includerefs", "", "File of references, one per line, whose transactions are the only ones kept; each a substring, or a regular expression after \"re:\"")
+	fs.StringVar(&payeeAliasesPath, "payeealiases", "", "CSV of pattern,payee lines replacing payees matching a regular expression with a canonical payee, the first match winning")
+	fs.StringVar(&flagPreset.ConstantPayee, "payee", "", "Payee of every transaction whose -payeecolumns are empty, for statements of a single payee")
+	fs.Var(&junkPayees, "junkpayees", "Comma separated payees, such as POS or PAYMENT, blanked whatever their case so Xero doesn't create contacts for them")
+	fs.StringVar(&payeeFallbackSpec, "payeefallback", "none", "Empty payees are left empty (\"none\"), take the first word of the Description (\"firstword\") or another field (\"firstword:Reference\"), or are set to any other value given")
+	fs.IntVar(&explainLine, "explain", 0, "Explain step by step how the row on this line of -file is transformed, then exit")
+	fs.BoolVar(&listColumnsOnly, "listcolumns", false, "Print the column names of -file one per line, as mappings refer to them, then exit")
+	fs.BoolVar(&countOnly, "countonly", false, "Only count the transactions in -file, applying the usual skip rules, and write no output")
+	fs.StringVar(&validateConfigPath, "validateconfig", "", "Check this JSON, YAML or TOML config file, against the headers of -file when given, then exit")
+	fs.StringVar(&environment, "environment", "", "Where the run's output is headed, such as sandbox or production, stamped into the logs, report and JSON summary and into output paths with {environment}")
+	fs.BoolVar(&jsonSummary, "jsonsummary", false, "Print the outcome of the run as a JSON object on stdout (needs -outfile)")
+	fs.IntVar(&maxRows, "maxrows", 0, "Split the output into numbered files (e.g. xero.1.csv) of at most this many transactions each")
+	fs.BoolVar(&runSelfCheck, "selfcheck", false, "Check the log directory, the output location and a sample transform, then exit")
+}
+
 func main() {
 	log.Info("Bank Statements Transform tool")
 	log.Info("Started at " + time.Now().UTC().String())
+	log.Info("Run " + runID)
 	log.Info("Parsing command line...")
 
-	flag.StringVar(&csvImportPath, "file", "", "CSV file to read from")
-	flag.StringVar(&csvOutputPath, "outfile", "", "CSV file to output to")
-	flag.StringVar(&logPath, "logpath", "~/logs/xero-bank-transform", "Path to console log files")
-	flag.BoolVar(&outputConsole, "outputconsole", true, "Enable console log")
+	defineFlags(flag.CommandLine)
 	flag.Parse()
 
+	summary.RunID = runID
+	if environment != "" {
+		summary.Environment = environment
+		logConsoleFormat, logFileFormat = logFormats(runID + " " + environment)
+		log.Warningf("*** Environment - %s ***", environment)
+	}
+	summary.Started = time.Now()
+	summary.Options = map[string]string{}
+	flag.Visit(func(f *flag.Flag) {
+		summary.Options[f.Name] = f.Value.String()
+	})
+	// Keep secrets out of reports
+	for _, secret := range []string{"zippassword", "header"} {
+		if _, ok := summary.Options[secret]; ok {
+			summary.Options[secret] = "********"
+		}
+	}
+
 	log.Warningf("CSV import file - %s", csvImportPath)
+	log.Warningf("Recurse into directories - %t", recurse)
+	log.Warningf("Latest file by - %s", latest)
+	log.Warningf("Trim leading spaces - %t", trimLeadingSpace)
+	log.Warningf("Maximum field size - %d", maxFieldSize)
 	log.Warningf("CSV output file - %s", csvOutputPath)
 	log.Warningf("Path to log files - %s", logPath)
+	log.Warningf("Create output directories - %t", mkdirOut)
 	log.Warningf("Enable console log - %t", outputConsole)
+	log.Warningf("Coalesce by - %s", coalesceBy)
+	log.Warningf("Bank preset - %s", bankName)
+	log.Warningf("Sanitize formulas - %s", sanitizeFormulas)
+	log.Warningf("Error strategy - %s", errorStrategy)
+	log.Warningf("Reject file - %s", rejectPath)
+	log.Warningf("Text case - %s", textCase)
+	log.Warningf("Daily report - %s", dailyReportPath)
+	log.Warningf("Pivot report - %s", pivotReportPath)
+	log.Warningf("Reconciliation - %s", reconcilePath)
+	log.Warningf("Categories - %s", categoriesPath)
+	log.Warningf("Maximum field lengths - %s", maxLengthsSpec)
+	log.Warningf("Row script - %s", rowScriptCommand)
+	log.Warningf("Amount locale - %s", numberLocale)
+	log.Warningf("Currency symbols - %s", currencySymbols.String())
+	log.Warningf("Minor units - %d", minorUnits)
+	log.Warningf("Reverse output - %t", reverseOutput)
+	log.Warningf("Group by transaction type - %s", groupByType.String())
+	log.Warningf("Report file - %s", reportPath)
+	log.Warningf("Warnings file - %s", warningsPath)
+	log.Warningf("Diff against - %s", diffAgainstPath)
+	log.Warningf("Output format - %s", outputFormat)
+	log.Warningf("Amount layout - %s", amountLayout)
+	log.Warningf("Output encoding - %s", outputEncoding)
+	log.Warningf("Fixed width layout - %s", fixedLayoutPath)
+	log.Warningf("Exchange rates - %s", ratesPath)
+	log.Warningf("Base currency - %s", baseCurrency)
+	log.Warningf("Filter - %s", filterSpec)
+	log.Warningf("Watermark file - %s", watermarkPath)
+	log.Warningf("Date locale - %s", dateLocaleSpec)
+	log.Warningf("From date - %s", fromSpec)
+	log.Warningf("To date - %s", toSpec)
+	log.Warningf("Last days - %d", lastDays)
+	log.Warningf("As of - %s", asOfSpec)
+	log.Warningf("Payee aliases - %s", payeeAliasesPath)
+	log.Warningf("Junk payees - %s", junkPayees.String())
+	log.Warningf("Excluded references - %s", excludeRefsPath)
+	log.Warningf("Opening balance - %s", openingBalanceAmount)
+	log.Warningf("Opening balance row - %s", openingBalanceSpec)
+	log.Warningf("Included references - %s", includeRefsPath)
+	log.Warningf("Transaction types - %s/%s", creditType, debitType)
+	log.Warningf("Zero amounts - %s", zeroPolicy)
+	log.Warningf("Missing amounts - %s", missingAmountPolicy)
+	log.Warningf("Uniform columns - %s", uniformColumnsPolicy)
+
+	if thousandsSeparator == "space" {
+		thousandsSeparator = " "
+	}
+	inputNumberFormat = numberFormat{thousands: thousandsSeparator, decimal: decimalSeparator}
+	if numberLocale != "" {
+		format, err := localeNumberFormat(numberLocale)
+		if err != nil {
+			log.Fatal(err)
+		}
+		inputNumberFormat = format
+	}
+	if inputNumberFormat.decimal == "" || inputNumberFormat.decimal == inputNumberFormat.thousands {
+		log.Fatalf("Invalid amount separators %+v", inputNumberFormat)
+	}
+	log.Debugf("Amount separators: thousands %q, decimal %q", inputNumberFormat.thousands, inputNumberFormat.decimal)
+
+	if err := validateRoundingMode(roundingMode); err != nil {
+		log.Fatal(err)
+	}
+	encoder, err := newOutputEncoder(outputEncoding, unencodable)
+	if err != nil {
+		log.Fatal(err)
+	}
+	outputEncoder = encoder
+	if minorUnits < 0 {
+		log.Fatalf("Invalid -minorunits %d, expected a positive number of minor units to the unit", minorUnits)
+	}
+	if err := validateSanitizeMode(sanitizeFormulas); err != nil {
+		log.Fatal(err)
+	}
+	if err := validateErrorStrategy(errorStrategy); err != nil {
+		log.Fatal(err)
+	}
+	if err := validateZeroPolicy(zeroPolicy); err != nil {
+		log.Fatal(err)
+	}
+	if err := validateZeroPolicy(missingAmountPolicy); err != nil {
+		log.Fatal(err)
+	}
+	if err := validateWarningsFormat(warningsFormat); err != nil {
+		log.Fatal(err)
+	}
+	if dateLocale, err = parseDateLocale(dateLocaleSpec); err != nil {
+		log.Fatal(err)
+	}
+	if amountLayout != layoutSigned && amountLayout != layoutSpentReceived {
+		log.Fatalf("Unknown -amountlayout %q, expected %s or %s", amountLayout, layoutSigned, layoutSpentReceived)
+	}
+	if headerRows < 1 {
+		log.Fatal("-headerrows must be at least 1")
+	}
+	if missingColumnsPolicy != missingColumnsFail && missingColumnsPolicy != missingColumnsWarn {
+		log.Fatalf("Unknown -missingcolumns %q, expected %s or %s", missingColumnsPolicy, missingColumnsFail, missingColumnsWarn)
+	}
+	if latest != "" && latest != latestModified && latest != latestNamed {
+		log.Fatalf("Unknown -latest %q, expected %s or %s", latest, latestModified, latestNamed)
+	}
+	if uniformColumnsPolicy != "" && uniformColumnsPolicy != missingColumnsFail && uniformColumnsPolicy != missingColumnsWarn {
+		log.Fatalf("Unknown -requireuniformcolumns %q, expected %s or %s", uniformColumnsPolicy, missingColumnsFail, missingColumnsWarn)
+	}
+	if err := validateTextCase(textCase); err != nil {
+		log.Fatal(err)
+	}
+	lengths, err := parseMaxLengths(maxLengthsSpec)
+	if err != nil {
+		log.Fatal(err)
+	}
+	maxLengths = lengths
+
+	presets, err := loadPresets(presetDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	preset, ok := presets[bankName]
+	if !ok {
+		log.Fatalf("Unknown bank preset %q, available presets: %s", bankName, strings.Join(presetNames(presets), ", "))
+	}
+	if configPath != "" {
+		log.Warningf("Config file - %s", configPath)
+		if preset, err = loadPresetFile(configPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+	// Flags given explicitly override the matching preset values
+	flag.Visit(func(f *flag.Flag) {
+		if override, ok := presetFlags[f.Name]; ok {
+			override(&preset, &flagPreset)
+		}
+	})
+	delimiter, err := delimiterRune(preset.Delimiter)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if trimLeadingSpace && unicode.IsSpace(delimiter) {
+		log.Fatal("-trimleadingspace can't be used with a whitespace delimiter, as the empty cells would be lost")
+	}
+	log.Debugf("Preset settings: %+v", preset)
+	if err := validateAmountStrategies(&preset); err != nil {
+		log.Fatal(err)
+	}
+
+	if fromDate, toDate, err = parseDateRange(fromSpec, toSpec, lastDays, asOfSpec); err != nil {
+		log.Fatal(err)
+	}
+	if !fromDate.IsZero() || !toDate.IsZero() {
+		log.Noticef("Writing transactions dated from %s to %s", dateRangeEnd(fromDate), dateRangeEnd(toDate))
+	}
+	if watermarkPath != "" {
+		if watermark, err = readWatermark(watermarkPath); err != nil {
+			log.Fatalf("Unable to read watermark from %s: %s", watermarkPath, err)
+		}
+		if watermark.IsZero() {
+			log.Noticef("No watermark in %s yet, processing every transaction", watermarkPath)
+		} else {
+			log.Noticef("Skipping transactions dated on or before the watermark %s", watermark.Format(watermarkLayout))
+		}
+	}
+
+	if sampleSpec != "" {
+		if sampleSeed == 0 {
+			sampleSeed = time.Now().UnixNano()
+		}
+		if sample, err = parseSample(sampleSpec, sampleSeed); err != nil {
+			log.Fatal(err)
+		}
+		log.Noticef("Sampling %s of the transactions with seed %d", sampleSpec, sampleSeed)
+	}
+
+	if timestampLocation, err = time.LoadLocation(timestampZone); err != nil {
+		log.Fatalf("Invalid -tz: %s", err)
+	}
+
+	if payeeDefault, err = parsePayeeFallback(payeeFallbackSpec); err != nil {
+		log.Fatal(err)
+	}
+	if preset.ConstantPayee != "" {
+		if payeeDefault != nil {
+			log.Fatal("Use either -payee or -payeefallback, as both fill in empty payees")
+		}
+		payeeDefault = &payeeFallback{literal: preset.ConstantPayee}
+	}
+	if payeeAliasesPath != "" {
+		aliasesFile := openFile(payeeAliasesPath)
+		payeeAliases, err = loadPayeeAliases(aliasesFile)
+		aliasesFile.Close()
+		if err != nil {
+			log.Fatalf("Unable to read payee aliases from %s: %s", payeeAliasesPath, err)
+		}
+		log.Debugf("%d payee aliases loaded", len(payeeAliases))
+	}
+	if categoriesPath != "" {
+		categoriesFile := openFile(categoriesPath)
+		categoryRules, err = loadCategoryRules(categoriesFile)
+		categoriesFile.Close()
+		if err != nil {
+			log.Fatalf("Unable to read categories from %s: %s", categoriesPath, err)
+		}
+		log.Debugf("%d category keywords loaded", len(categoryRules))
+	}
+	if excludeRefsPath != "" {
+		excludeRefs = loadRefListFile(excludeRefsPath)
+	}
+	if includeRefsPath != "" {
+		includeRefs = loadRefListFile(includeRefsPath)
+	}
+	if openingBalanceSpec != "" {
+		if openingBalance, err = newOpeningBalance(openingBalanceSpec, openingBalanceAmount); err != nil {
+			log.Fatal(err)
+		}
+	} else if openingBalanceAmount != "" && reconcilePath == "" {
+		log.Fatal("-openingbalance is only used with -emitopeningbalance or -reconcileout")
+	}
+
+	if jsonSummary && csvOutputPath == "" {
+		log.Fatal("-jsonsummary needs an -outfile, as stdout is taken by the summary")
+	}
+	if outputFormats, outputPaths, err = outputTargets(outputFormat, csvOutputPath); err != nil {
+		log.Fatal(err)
+	}
+
+	if maxRows > 0 && csvOutputPath == "" {
+		log.Fatal("-maxrows needs an -outfile to number the files after")
+	}
+	if diffAgainstPath != "" {
+		if outputFormats[0] != "csv" || csvOutputPath == "" || maxRows > 0 || isSpecialFile(outputPaths[0]) {
+			log.Fatal("-diffagainst needs a single CSV -outfile to compare")
+		}
+		if diffOutPath == "" && jsonSummary {
+			log.Fatal("-diffagainst with -jsonsummary needs a -diffout, as stdout is taken by the summary")
+		}
+	}
 
+	if !includeIndex {
+		indexOrder = ""
+	} else if indexOrder != indexOutput && indexOrder != indexSource {
+		log.Fatalf("Unknown -indexorder %q, expected %s or %s", indexOrder, indexOutput, indexSource)
+	}
+
+	if fixedLayoutPath != "" {
+		if fixedLayout, err = loadFixedLayout(fixedLayoutPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if filterSpec != "" {
+		if filter, err = parseFilter(filterSpec); err != nil {
+			log.Fatalf("Invalid -filter: %s", err)
+		}
+	}
+
+	if coalesceBy != "" {
+		if _, err := transformField(&Transform{}, coalesceBy); err != nil {
+			log.Fatalf("Invalid -coalesceby: %s", err)
+		}
+	}
+
+	if err := validateTimestampFormat(timestampFormat); err != nil {
+		log.Fatal(err)
+	}
 	// Include timestamp into log file names
-	timeNowStr := time.Now().UTC().Format("2006-01-02T15-04-05Z")
+	timeNowStr := time.Now().UTC().Format(timestampFormat)
 
 	consoleLogFileName := "console_" + timeNowStr + ".log"
 
+	// Output paths may be timestamped too, and name the environment
+	csvOutputPath = expandOutputPath(csvOutputPath, timeNowStr)
+	for i := range outputPaths {
+		outputPaths[i] = expandOutputPath(outputPaths[i], timeNowStr)
+	}
+	rejectPath = expandOutputPath(rejectPath, timeNowStr)
+	dailyReportPath = expandOutputPath(dailyReportPath, timeNowStr)
+	pivotReportPath = expandOutputPath(pivotReportPath, timeNowStr)
+	reconcilePath = expandOutputPath(reconcilePath, timeNowStr)
+	reportPath = expandOutputPath(reportPath, timeNowStr)
+	warningsPath = expandOutputPath(warningsPath, timeNowStr)
+	diffOutPath = expandOutputPath(diffOutPath, timeNowStr)
+
 	// Expand "~" to user home directory in log path
 	usr, _ := user.Current()
 	dir := usr.HomeDir
 	logPath = strings.Replace(logPath, "~", dir, 1)
 
+	if validateConfigPath != "" {
+		if !validateConfig(validateConfigPath, csvImportPath) {
+			exitWith(exitCheckFailed, "Config "+validateConfigPath+" is unusable")
+		}
+		log.Noticef("Config %s is usable", validateConfigPath)
+		return
+	}
+
+	if runSelfCheck {
+		if !selfCheck() {
+			exitWith(exitCheckFailed, "Self check failed")
+		}
+		log.Notice("Self check passed")
+		return
+	}
+
 	// Create log path if it doesn't exist
-	err := os.MkdirAll(logPath, 0777)
+	err = os.MkdirAll(logPath, 0777)
 	// If unable to create the directory, terminate
 	if err != nil {
 		log.Fatal(err)
@@ -99,126 +719,855 @@ func main() {
 		logging.SetBackend(logConsolePrettyBackend, logFilePrettyBackend)
 	}
 
-	// CSV Reader
-	csvImportFile := openFile(csvImportPath)
-	defer csvImportFile.Close()
-	csvr := csv.NewReader(csvImportFile)
+	inputs, err := openInputs(csvImportPath)
+	if err != nil {
+		exitWith(exitCode(err), err)
+	}
 
-	csvOutputFile := createFile(csvOutputPath)
-	defer csvOutputFile.Close()
-	csvw := csv.NewWriter(csvOutputFile)
+	if generateConfigPath != "" {
+		generateConfig(inputs[0], &preset, delimiter, generateConfigPath)
+		return
+	}
 
-	var headers []string
-	// Read header line
-	for {
-		row, err := csvr.Read()
-		if err == io.EOF {
-			break
+	if listColumnsOnly {
+		for _, in := range inputs {
+			if err := listColumns(os.Stdout, in, &preset, delimiter); err != nil {
+				exitWith(exitCode(err), err)
+			}
+		}
+		return
+	}
+
+	if countOnly {
+		total := 0
+		for _, in := range inputs {
+			count, err := countTransactions(in, &preset, delimiter)
+			if err != nil {
+				exitWith(exitCode(err), err)
+			}
+			log.Noticef("%d transactions in %s", count, in.name)
+			total += count
+		}
+		if len(inputs) > 1 {
+			log.Noticef("%d transactions in total", total)
 		}
+		return
+	}
+
+	if len(redactEntries) > 0 {
+		if redaction, err = newRedactor(redactEntries, redactMode); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if ratesPath != "" {
+		if missingRate != missingRateSkip && missingRate != missingRatePassThrough {
+			log.Fatalf("Unknown -missingrate %q, expected %s or %s", missingRate, missingRateSkip, missingRatePassThrough)
+		}
+		if preset.Columns.Currency == "" {
+			log.Fatal("-rates needs a currency column, use -currencycolumn")
+		}
+		baseCurrency = strings.ToUpper(baseCurrency)
+		ratesFile := openFile(ratesPath)
+		rates, err = loadRates(ratesFile)
+		ratesFile.Close()
+		if err != nil {
+			log.Fatalf("Unable to read exchange rates from %s: %s", ratesPath, err)
+		}
+		log.Debugf("Exchange rates loaded for %d currencies", len(rates))
+	}
+
+	if rowScriptCommand != "" {
+		script, err = startRowScript(rowScriptCommand)
 		if err != nil {
 			log.Fatal(err)
 		}
-		// There is extra guff in the export file, so only read the correct header
-		if row[0] == " Date" && row[1] == "Description" {
-			for _, heading := range row {
-				if heading == " Date" {
-					headers = append(headers, "Date")
-					continue
-				}
-				if heading == "Bank     Reference" {
-					headers = append(headers, "Bank Reference")
-					continue
-				}
-				if heading == "Customer  Reference" {
-					headers = append(headers, "Customer Reference")
-					continue
-				}
-				if heading == "Running  Balance  " {
-					headers = append(headers, "Running Balance")
-					continue
-				}
-				headers = append(headers, heading)
+		defer script.close()
+	}
+
+	if includeSource {
+		extraColumns = append(extraColumns,
+			outputColumn{header: "Source", value: func(t *transaction) string { return t.source }},
+		)
+	}
+	if includeFITID {
+		extraColumns = append(extraColumns, outputColumn{header: "FITID", value: transactionFITID})
+	}
+	if keepRawAmounts {
+		extraColumns = append(extraColumns,
+			outputColumn{header: "Raw Debit", value: func(t *transaction) string { return t.rawDebit }},
+			outputColumn{header: "Raw Credit", value: func(t *transaction) string { return t.rawCredit }},
+		)
+	}
+	if keepOriginal {
+		extraColumns = append(extraColumns,
+			outputColumn{header: "Original Amount", value: func(t *transaction) string { return t.originalAmount }},
+			outputColumn{header: "Original Currency", value: func(t *transaction) string { return t.originalCurrency }},
+		)
+	}
+
+	// Explained with the same configuration as the real transform
+	if explainLine > 0 {
+		out, err := newTransactionWriter(outputFormats[0], ioutil.Discard)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = Run(context.Background(), inputs, &preset, delimiter, out, ProgressHook{})
+		if errors.Is(err, errExplained) {
+			return
+		}
+		if err != nil {
+			exitWith(exitCode(err), err)
+		}
+		exitWith(1, fmt.Sprintf("Line %d was not read as a transaction, it may be in the preamble, the header or the footer, or past the end of the input", explainLine))
+	}
+
+	// Check every output can be created before transforming anything
+	outputDirs := append([]string{rejectPath, dailyReportPath, pivotReportPath, reconcilePath, reportPath, warningsPath, watermarkPath}, outputPaths...)
+	for _, spec := range alsoOutputs {
+		outputDirs = append(outputDirs, strings.SplitN(spec, "=", 2)[0])
+	}
+	for _, path := range outputDirs {
+		if err := prepareOutputDir(path); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if rejectPath != "" {
+		rejectFile := createFile(rejectPath)
+		defer rejectFile.Close()
+		rejectWriter = csv.NewWriter(rejectFile)
+	}
+
+	if pivotReportPath != "" {
+		pivotReport = pivotTotals{}
+	}
+	if dailyReportPath != "" {
+		dailyReport = newDailyTotals()
+	}
+	if reconcilePath != "" {
+		reconcile = &reconciliation{}
+		if openingBalance != nil {
+			reconcile.opening, reconcile.hasOpening = openingBalance.amount, true
+		} else if openingBalanceAmount != "" {
+			if reconcile.opening, err = parseAmount(openingBalanceAmount); err != nil {
+				log.Fatalf("Invalid opening balance %q: %s", openingBalanceAmount, err)
 			}
+			reconcile.hasOpening = true
 		}
-		if len(headers) > 0 {
-			break
+	}
+
+	if onTimeout != onTimeoutKeep && onTimeout != onTimeoutDiscard {
+		log.Fatalf("Unknown -ontimeout %q, expected %s or %s", onTimeout, onTimeoutKeep, onTimeoutDiscard)
+	}
+
+	defer discardOutputs()
+	var writers []transactionWriter
+	for i, format := range outputFormats {
+		var writer transactionWriter
+		if maxRows > 0 {
+			writer, err = newSplitTransactionWriter(format, outputPaths[i], maxRows)
+		} else {
+			var file *outputFile
+			if file, err = createOutputFile(outputPaths[i]); err == nil {
+				writer, err = newTransactionWriter(format, encodeOutput(file))
+			}
+		}
+		if err != nil {
+			fatalOutput(err)
+		}
+		writers = append(writers, writer)
+	}
+	out := writers[0]
+	if len(writers) > 1 {
+		out = multiTransactionWriter(writers)
+	}
+
+	for _, spec := range alsoOutputs {
+		log.Warningf("Further output - %s", spec)
+		extra, err := openExtraOutput(spec, &preset)
+		if err != nil {
+			fatalOutput(err)
+		}
+		extraOutputs = append(extraOutputs, extra)
+	}
+
+	if err := out.WriteHeader(); err != nil {
+		fatalOutput(err)
+	}
+	if openingBalance != nil {
+		if err := writeOpeningBalance(out); err != nil {
+			fatalOutput(err)
 		}
 	}
-	if len(headers) == 0 {
-		log.Fatal("Unable to read header row")
+
+	hook := ProgressHook{Every: progressEvery, Func: func(p Progress) {
+		log.Noticef("Progress: %d rows, %d transactions read, %d written, %d skipped, %d rejected", p.Rows, p.Read, p.Written, p.Skipped, p.Rejected)
+	}}
+
+	// Stop cleanly on Ctrl-C or a termination request
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	err = runWithDeadline(ctx, inputs, &preset, delimiter, out, hook)
+	if flushErr := finishOutput(out); flushErr != nil {
+		fatalOutput(flushErr)
+	}
+	switch err {
+	case nil:
+	case context.Canceled:
+		if commitErr := commitOutputs(); commitErr != nil {
+			fatalOutput(commitErr)
+		}
+		exitWith(exitInterrupted, fmt.Sprintf("Interrupted, %d transactions written to %s", finalSummary().Written, csvOutputPath))
+	case context.DeadlineExceeded:
+		if onTimeout == onTimeoutDiscard {
+			discardOutputs()
+			exitWith(exitTimeout, fmt.Sprintf("Timed out after %s, output discarded", timeout))
+		}
+		if commitErr := commitOutputs(); commitErr != nil {
+			fatalOutput(commitErr)
+		}
+		exitWith(exitTimeout, fmt.Sprintf("Timed out after %s, %d transactions written to %s", timeout, finalSummary().Written, csvOutputPath))
+	default:
+		discardOutputs()
+		exitWith(exitCode(err), err)
+	}
+	if err := commitOutputs(); err != nil {
+		fatalOutput(err)
+	}
+	if len(outputFormats) > 1 {
+		for i, format := range outputFormats {
+			log.Noticef("%s output written to %s", format, outputPaths[i])
+		}
+	}
+	if diffAgainstPath != "" {
+		var diffOut io.Writer = os.Stdout
+		if diffOutPath != "" {
+			diffFile := createFile(diffOutPath)
+			defer diffFile.Close()
+			diffOut = diffFile
+		}
+		diff, err := diffOutputs(diffAgainstPath, outputPaths[0], diffOut)
+		if err != nil {
+			log.Fatalf("Unable to compare with %s: %s", diffAgainstPath, err)
+		}
+		log.Noticef("Against %s: %d rows added, %d removed and %d changed", diffAgainstPath, diff.added, diff.removed, diff.changed)
+	}
+	if watermarkPath != "" && summary.LastDate.After(watermark) {
+		if err := writeWatermark(watermarkPath, summary.LastDate); err != nil {
+			log.Fatal(err)
+		}
+		log.Noticef("Watermark moved to %s", summary.LastDate.Format(watermarkLayout))
+	}
+
+	if dailyReport != nil {
+		dailyReportFile := createFile(dailyReportPath)
+		defer dailyReportFile.Close()
+		if err := dailyReport.write(dailyReportFile); err != nil {
+			log.Fatal(err)
+		}
+		log.Noticef("Daily report written to %s", dailyReportPath)
+	}
+	if pivotReport != nil {
+		pivotReportFile := createFile(pivotReportPath)
+		defer pivotReportFile.Close()
+		if err := pivotReport.write(pivotReportFile); err != nil {
+			log.Fatal(err)
+		}
+		log.Noticef("Pivot report written to %s", pivotReportPath)
 	}
-	log.Debugf("File headers: %s", headers)
+	if reconcile != nil {
+		reconcileFile := createFile(reconcilePath)
+		defer reconcileFile.Close()
+		if err := reconcile.write(reconcileFile); err != nil {
+			log.Fatal(err)
+		}
+		if summary.BalanceMismatches > 0 {
+			log.Warningf("%d of %d reconciled rows don't match their stated balance, see %s", summary.BalanceMismatches, summary.Reconciled, reconcilePath)
+		} else {
+			log.Noticef("%d rows reconciled with their stated balance in %s", summary.Reconciled, reconcilePath)
+		}
+	}
+
+	summary.Finished = time.Now()
+	if reportPath != "" {
+		reportFile := createFile(reportPath)
+		defer reportFile.Close()
+		if err := summary.writeReport(reportFile); err != nil {
+			log.Fatal(err)
+		}
+		log.Noticef("Run report written to %s", reportPath)
+	}
+	saveWarnings()
+
+	reportIssues()
+	if jsonSummary {
+		if err := summary.writeJSON(os.Stdout, "ok", nil); err != nil {
+			log.Fatal(err)
+		}
+	}
+	log.Warning("Transform completed")
+	log.Noticef("%d total transactions found in CSV", summary.Read)
+	log.Noticef("%d transactions written", summary.Written)
+	if filter != nil {
+		log.Noticef("%d transactions matched the filter", summary.Matched)
+	}
+	for _, e := range extraOutputs {
+		log.Noticef("%d transactions written to %s", summary.Outputs[e.path], e.path)
+	}
+	if summary.Rejected > 0 {
+		log.Noticef("%d rows rejected", summary.Rejected)
+	}
+	if excludeRefs != nil {
+		log.Noticef("%d transactions dropped by -excluderefs", summary.ExcludedRefs)
+	}
+	if includeRefs != nil {
+		log.Noticef("%d transactions dropped by -includerefs", summary.NotIncludedRefs)
+	}
+	if summary.ZeroAmounts > 0 {
+		log.Noticef("%d transactions with a zero amount %s", summary.ZeroAmounts, map[string]string{zeroKeep: "kept", zeroDrop: "dropped", zeroReject: "rejected"}[zeroPolicy])
+	}
+	if unevenRows > 0 {
+		log.Noticef("%d rows with a different number of cells from their header", unevenRows)
+	}
+	if summary.MissingAmounts > 0 {
+		log.Noticef("%d transactions with no amount %s", summary.MissingAmounts, map[string]string{zeroKeep: "kept", zeroDrop: "dropped", zeroReject: "rejected"}[missingAmountPolicy])
+	}
+	if redaction != nil {
+		log.Noticef("%d values redacted", summary.Redactions)
+	}
+	log.Info("Completed at " + time.Now().UTC().String())
+	log.Info("Run " + runID + " completed")
+}
+
+// runWithDeadline runs the transform until it finishes or the context is done. A run blocked
+// reading its input is abandoned shortly after the context is done, so a hung read can't stall
+// the process; the output is guarded so the abandoned run can't write to it any more, and its
+// summary is no longer read.
+func runWithDeadline(ctx context.Context, inputs []input, preset *Preset, delimiter rune, out transactionWriter, hook ProgressHook) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(ctx, inputs, preset, delimiter, out, hook)
+	}()
 
-	xeroCSVHeaders := []string{
-		"*Date",
-		"*Amount",
-		"Payee",
-		"Description",
-		"Reference",
-		"Cheque Number",
-		"Transaction Type",
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+	// Give the run a moment to notice between rows
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(time.Second):
+		log.Warning("Abandoning a run blocked on its input")
+		runAbandoned = true
+		return ctx.Err()
+	}
+}
+
+// Run transforms the transactions of every input, writing them to out.
+// It stops early with the context's error if the context is cancelled.
+// Progress is published for CurrentProgress as it goes, and passed to the hook if it has a Func.
+func Run(ctx context.Context, inputs []input, preset *Preset, delimiter rune, out transactionWriter, hook ProgressHook) error {
+	defer publishProgress(false, hook)
+	// Transactions held back until every input is read
+	var pending []*transaction
+	for _, input := range inputs {
+		summary.Inputs = append(summary.Inputs, input.name)
+		var err error
+		pending, err = transformInput(ctx, input.name, input.reader, preset, delimiter, out, pending, hook)
+		input.reader.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if !holdBack() {
+		return nil
+	}
+
+	pending = arrangePending(pending)
+	if sample != nil {
+		pending = sample.pick(pending)
+	}
+	for i, t := range pending {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if groupSeparator && i > 0 && t.TransactionType != pending[i-1].TransactionType {
+			if err := writeGroupSeparator(out); err != nil {
+				return fmt.Errorf("%w writing output: %s", ErrIO, err)
+			}
+		}
+		if err := writeTransaction(out, t); err != nil {
+			return err
+		}
+	}
+	for _, e := range extraOutputs {
+		if err := e.writePending(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// arrangePending coalesces and orders the held back transactions for writing
+func arrangePending(pending []*transaction) []*transaction {
+	if coalesceBy != "" {
+		pending = coalesceTransactions(pending, coalesceBy)
+		log.Noticef("%d transactions after coalescing by %s", len(pending), coalesceBy)
+	}
+	if reverseOutput {
+		for i, j := 0, len(pending)-1; i < j; i, j = i+1, j-1 {
+			pending[i], pending[j] = pending[j], pending[i]
+		}
+	}
+	if len(groupByType) > 0 {
+		groupTransactions(pending)
+	}
+	return pending
+}
+
+// holdBack reports whether transactions must be kept in memory until every input has been read,
+// rather than written as they are read
+func holdBack() bool {
+	return coalesceBy != "" || reverseOutput || len(groupByType) > 0 || (sample != nil && sample.count > 0)
+}
+
+// transformInput reads the transactions from a single statement, writing them to the output
+// or adding them to pending when they need to be held back until every input has been read
+func transformInput(ctx context.Context, name string, r io.Reader, preset *Preset, delimiter rune, out transactionWriter, pending []*transaction, hook ProgressHook) ([]*transaction, error) {
+	log.Infof("Reading %s", name)
+	csvr := newStatementReader(r, delimiter)
+	// The amount strategy is settled for each file, so a copy of the preset keeps the choice
+	filePreset := *preset
+	preset = &filePreset
+	amountChosen := len(preset.AmountStrategies) == 0
+
+	headers, err := readHeader(csvr, name, preset)
+	if err != nil {
+		return pending, err
+	}
+	headerLine, _ := csvr.FieldPos(0)
+	if err := checkMappedColumns(headers, preset, name, headerLine); err != nil {
+		return pending, err
+	}
+	writeRejectHeader(headers)
+	// Header delimited sections read, each possibly with columns of its own
+	sections := 1
+	defer func() {
+		if sections > 1 {
+			log.Noticef("%d sections in %s", sections, name)
+		}
+	}()
+
+	dateOrder := &dateOrderCheck{name: name}
+	defer dateOrder.report()
+	var balances *balanceCheck
+	if preset.Columns.Balance != "" && containsString(headers, preset.Columns.Balance) {
+		balances = &balanceCheck{name: name}
+		defer balances.check()
+	}
+	if reconcile != nil {
+		if balances == nil {
+			log.Warningf("%s has no running balance column, so it isn't reconciled", name)
+		}
+		reconcile.startStatement()
+	}
+
+	// Transaction held back until it's clear no continuation rows follow it
+	var held *transaction
+	var heldData map[string]string
+	// emit finishes a transaction and writes it, or adds it to pending
+	emit := func(t *transaction, data map[string]string) error {
+		line := t.lines[0]
+		if !prepareTransaction(t, data, preset, line) {
+			summary.Skipped++
+			if explaining(line) {
+				return errExplained
+			}
+			return nil
+		}
+		if !keepReference(t, line) {
+			if explaining(line) {
+				return errExplained
+			}
+			return nil
+		}
+		if filter != nil {
+			if !filter.match(t) {
+				log.Debugf("Line %d doesn't match the filter", line)
+				if explainedSkip(line, "Not written as it doesn't match -filter %s", filterSpec) {
+					return errExplained
+				}
+				return nil
+			}
+			explainf(line, "Matches -filter %s", filterSpec)
+			summary.Matched++
+		}
+		if sample != nil && !sample.keep() {
+			if explainedSkip(line, "Not written as it was left out of the -sample") {
+				return errExplained
+			}
+			return nil
+		}
+		if holdBack() {
+			pending = append(pending, t)
+			if explaining(line) {
+				explainf(line, "Held back until every input is read, to be coalesced, reversed or sampled")
+				explainResult(t, preset)
+				return errExplained
+			}
+			return nil
+		}
+		if explaining(line) {
+			explainResult(t, preset)
+			return errExplained
+		}
+		if err := writeTransaction(out, t); err != nil {
+			return err
+		}
+		if err := flushOutput(out); err != nil && err != errOutputClosed {
+			return fmt.Errorf("%w writing output: %s", ErrIO, err)
+		}
+		return nil
+	}
+	// emitHeld emits the held transaction, if any
+	emitHeld := func() error {
+		if held == nil {
+			return nil
+		}
+		t := held
+		held = nil
+		return emit(t, heldData)
 	}
 
-	csvw.Write(xeroCSVHeaders)
 	// Read transactions from CSV
 	for {
+		if err := ctx.Err(); err != nil {
+			return pending, err
+		}
+		// Published before reading too, so a run left blocked on its input has reported the rows it finished
+		publishProgress(false, hook)
 		row, err := csvr.Read()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return pending, ctxErr
+		}
 		if err == io.EOF {
 			break
 		}
+		publishProgress(true, hook)
+		if err == nil && isEndMarker(row, preset.EndMarkers) {
+			line, _ := csvr.FieldPos(0)
+			log.Noticef("Reached the footer of %s on line %d, ignoring the rest", name, line)
+			break
+		}
+		if err == nil && isOversizedRow(row) {
+			line, _ := csvr.FieldPos(0)
+			warnRow(line, "Skipping line %d as it holds a field larger than -maxfieldsize %d bytes", line, maxFieldSize)
+			summary.Skipped++
+			if explainedSkip(line, "Skipped as it holds a field larger than -maxfieldsize") {
+				return pending, errExplained
+			}
+			continue
+		}
+		// Redacted before anything else sees the row, leaving header rows be so they can be recognised.
+		// Rows that failed to parse are redacted as far as they were read.
+		if redaction != nil && len(row) > 0 && !matchesSignature(row, preset.HeaderSignature) {
+			summary.Redactions += redaction.apply(headers, row)
+		}
+		if joinContinuations && err == nil {
+			line, _ := csvr.FieldPos(0)
+			if text, ok := continuationText(headers, row, preset); ok {
+				if held == nil {
+					warnRow(line, "No transaction to join the continuation %q on line %d to", text, line)
+					summary.Skipped++
+					if explainedSkip(line, "Skipped as a continuation with no transaction before it") {
+						return pending, errExplained
+					}
+					continue
+				}
+				held.Reference = strings.TrimSpace(held.Reference + " " + text)
+				held.lines = append(held.lines, line)
+				log.Noticef("Joined the continuation %q on line %d to the transaction on line %d", text, line, held.lines[0])
+				if explainedSkip(line, "Continuation %q joined to the Reference of the transaction on line %d", text, held.lines[0]) {
+					return pending, errExplained
+				}
+				continue
+			}
+			if err := emitHeld(); err != nil {
+				return pending, err
+			}
+		}
 		if err != nil {
-			log.Fatal(err)
+			// The row is lost, but reading carries on from the next line
+			if parseErr, ok := err.(*csv.ParseError); ok {
+				if err := rejectRow(parseErr.StartLine, row, parseErr.Err.Error()); err != nil {
+					return pending, err
+				}
+				continue
+			}
+			return pending, fmt.Errorf("%w reading %s: %s", ErrIO, name, err)
 		}
 
-		data := map[string]string{}
-		for i, v := range row {
-			data[headers[i]] = v
+		// Source line of the row, so messages can point at it in the original file
+		line, _ := csvr.FieldPos(0)
+		if explaining(line) {
+			fmt.Fprintf(explainOutput, "Line %d of %s: %q\n", line, name, row)
 		}
 
-		log.Warningf("Next transaction: %s", data)
-		if len(data["Date"]) == 0 || data["Date"] == "<nil>" {
+		if matchesSignature(row, preset.HeaderSignature) {
+			if row, err = joinHeaderRows(csvr, row); err != nil {
+				return pending, fmt.Errorf("%w reading the header on line %d of %s: %s", ErrNoHeader, line, name, err)
+			}
+			next := sectionHeaders(headers, row, preset, line)
+			if strings.Join(next, "\x00") != strings.Join(headers, "\x00") {
+				if err := checkMappedColumns(next, preset, name, line); err != nil {
+					return pending, err
+				}
+			}
+			headers = next
+			sections++
+			summary.Skipped++
+			if explainedSkip(line, "Header row, giving the columns %q", headers) {
+				return pending, errExplained
+			}
 			continue
 		}
-		if data["Date"] == "Transactions" {
+		if err := checkUniformColumns(headers, row, name, line); err != nil {
+			return pending, err
+		}
+		data, ignored, err := mapRow(headers, row)
+		if err != nil {
+			if err := rejectRow(line, row, err.Error()); err != nil {
+				return pending, err
+			}
 			continue
 		}
-		if data["Date"] == " Date" {
+		if ignored > 0 {
+			log.Debugf("Ignoring %d trailing empty cells on line %d", ignored, line)
+		}
+
+		log.Warningf("Next transaction on line %d: %s", line, data)
+		explainf(line, "Cells by column: %s", data)
+		if skipRow(row, data, preset, line) {
+			summary.Skipped++
+			if explaining(line) {
+				return pending, errExplained
+			}
 			continue
 		}
-		csvTransactionsTotal++
+		summary.Read++
+		if err := dateOrder.add(data[preset.Columns.Date], line); err != nil {
+			return pending, err
+		}
+
+		for _, e := range extraOutputs {
+			if err := e.add(data, name, line); err != nil {
+				return pending, err
+			}
+		}
 
 		// Prepare Xero Transaction
-		xeroTransaction := &Transform{
-			Date:         data["Date"],
-			Payee:        "",
-			Description:  data["Customer Reference"],
-			Reference:    data["Description"] + " " + data["Bank Reference"],
-			ChequeNumber: "",
-		}
-		if data["Credit"] != "" && data["Credit"] != "<nil>" {
-			xeroTransaction.Amount = data["Credit"]
-			xeroTransaction.TransactionType = "Credit"
-		}
-		if data["Debit"] != "" && data["Debit"] != "<nil>" {
-			xeroTransaction.Amount = "-" + data["Debit"]
-			xeroTransaction.TransactionType = "Debit"
-		}
-		csvw.Write([]string{
-			xeroTransaction.Date,
-			xeroTransaction.Amount,
-			xeroTransaction.Payee,
-			xeroTransaction.Description,
-			xeroTransaction.Reference,
-			xeroTransaction.ChequeNumber,
-			xeroTransaction.TransactionType,
-		})
-		csvw.Flush()
-	}
-	csvw.Flush()
+		xeroTransaction, err := buildTransform(data, preset, line)
+		if errors.Is(err, errNotTimestamp) {
+			warnRow(line, "Skipping line %d: %s", line, err)
+			summary.Skipped++
+			if explainedSkip(line, "Skipped: %s", err) {
+				return pending, errExplained
+			}
+			continue
+		}
+		if err != nil {
+			if err := rejectRow(line, row, err.Error()); err != nil {
+				return pending, err
+			}
+			continue
+		}
+		xeroTransaction.index = summary.Read
+		xeroTransaction.source = sourceName(name)
+		if !amountChosen && xeroTransaction.amountStrategy != "" {
+			log.Noticef("Reading the amounts of %s with the %s strategy, the first to give a valid amount on line %d", name, xeroTransaction.amountStrategy, line)
+			preset.AmountStrategies = []string{xeroTransaction.amountStrategy}
+			amountChosen = true
+		}
+		if keep, err := keepZeroAmount(xeroTransaction, row, line); !keep {
+			if err != nil {
+				return pending, err
+			}
+			if explaining(line) {
+				return pending, errExplained
+			}
+			continue
+		}
+		if keep, err := keepMissingAmount(xeroTransaction, row, line); !keep {
+			if err != nil {
+				return pending, err
+			}
+			if explaining(line) {
+				return pending, errExplained
+			}
+			continue
+		}
+		if balances != nil {
+			balances.add(xeroTransaction, data[preset.Columns.Balance])
+			if reconcile != nil {
+				reconcile.add(xeroTransaction, data[preset.Columns.Balance], name)
+			}
+		}
+		if joinContinuations {
+			held, heldData = xeroTransaction, data
+			continue
+		}
+		if err := emit(xeroTransaction, data); err != nil {
+			return pending, err
+		}
+	}
 
-	log.Warning("Transform completed")
-	log.Noticef("%d total transactions found in CSV", csvTransactionsTotal)
-	log.Info("Completed at " + time.Now().UTC().String())
+	if err := emitHeld(); err != nil {
+		return pending, err
+	}
+	return pending, nil
+}
+
+// skipRow reports whether a mapped row is to be skipped as blank or a section marker
+func skipRow(row []string, data map[string]string, preset *Preset, line int) bool {
+	if len(data[preset.Columns.Date]) == 0 || data[preset.Columns.Date] == "<nil>" {
+		explainf(line, "Skipped as the date column %s is empty", preset.Columns.Date)
+		return true
+	}
+	if isSectionMarker(row[0], preset.SectionMarkers) || isSectionMarker(data[preset.Columns.Date], preset.SectionMarkers) {
+		log.Debugf("Skipping section marker on line %d", line)
+		explainf(line, "Skipped as a section marker")
+		return true
+	}
+	return false
+}
+
+// prepareTransaction converts, scripts and tidies a mapped transaction ready for writing,
+// returning false when it is to be skipped
+func prepareTransaction(t *transaction, data map[string]string, preset *Preset, line int) bool {
+	if belowWatermark(t) {
+		log.Debugf("Skipping line %d dated at or before the watermark", line)
+		explainf(line, "Skipped as it is dated at or before the -watermarkfile date")
+		return false
+	}
+	if outsideDateRange(t) {
+		log.Debugf("Skipping line %d dated outside the date range", line)
+		explainf(line, "Skipped as it is dated outside -from and -to, or -lastdays")
+		return false
+	}
+	if rates != nil {
+		converted := true
+		explainStep(t, line, "Currency conversion", func() { converted = convertCurrency(t, data[preset.Columns.Currency], line) })
+		if !converted {
+			explainf(line, "Skipped for want of an exchange rate, see -missingrate")
+			return false
+		}
+	}
+	if script != nil {
+		explainStep(t, line, "The row script", func() { script.apply(t, data) })
+	}
+	if len(junkPayees) > 0 {
+		explainStep(t, line, "The junk payees", func() { blankJunkPayee(t.Transform, junkPayees, line) })
+	}
+	if payeeDefault != nil {
+		explainStep(t, line, "The payee fallback", func() { payeeDefault.apply(t.Transform) })
+	}
+	if payeeAliases != nil {
+		explainStep(t, line, "The payee aliases", func() { applyPayeeAliases(t.Transform, payeeAliases, line) })
+	}
+	if textCase != caseNone {
+		explainStep(t, line, "Changing the text case", func() { changeTransformCase(t.Transform, textCase) })
+	}
+	if sanitizeFormulas != sanitizeNone {
+		explainStep(t, line, "Sanitizing formulas", func() { sanitizeTransform(t.Transform, sanitizeFormulas, line) })
+	}
+	explainStep(t, line, "Blanking empty fields", func() { blankEmptyFields(t.Transform) })
+	explainStep(t, line, "Truncation", func() { truncateTransform(t.Transform, maxLengths, truncateEllipsis, line) })
+	return true
+}
+
+// newStatementReader creates a CSV reader for a bank statement
+func newStatementReader(r io.Reader, delimiter rune) *csv.Reader {
+	csvr := csv.NewReader(newFieldSizeGuard(r, delimiter, maxFieldSize))
+	csvr.Comma = delimiter
+	// Preambles, footers and rows saved by spreadsheets often have differing numbers of cells
+	csvr.FieldsPerRecord = -1
+	// Header signatures and column names are compared with their spaces trimmed, so trimming cells
+	// here only changes the values written
+	csvr.TrimLeadingSpace = trimLeadingSpace
+	return csvr
+}
+
+// writeTransaction writes a Xero transaction to the output and adds it to any reports
+func writeTransaction(out transactionWriter, t *transaction) error {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	if outputClosed {
+		return errOutputClosed
+	}
+	summary.addWritten(t)
+	if dailyReport != nil {
+		dailyReport.add(t)
+	}
+	if pivotReport != nil {
+		pivotReport.add(t)
+	}
+	if err := out.Write(t); err != nil {
+		return fmt.Errorf("%w writing output: %s", ErrIO, err)
+	}
+	return nil
+}
+
+// validateTimestampFormat checks a timestamp layout holds at least one time element and
+// gives a valid file name
+func validateTimestampFormat(layout string) error {
+	sample := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC).Format(layout)
+	if sample == layout {
+		return fmt.Errorf("-timestampformat %q has no Go time layout elements such as 2006 or 15", layout)
+	}
+	if strings.ContainsAny(sample, `/\`) {
+		return fmt.Errorf("-timestampformat %q gives %q, which can't be part of a file name", layout, sample)
+	}
+	return nil
+}
+
+// expandOutputPath replaces the {timestamp} and {environment} placeholders in an output path
+func expandOutputPath(path string, timestamp string) string {
+	path = strings.Replace(path, "{timestamp}", timestamp, -1)
+	return strings.Replace(path, "{environment}", environment, -1)
+}
+
+// exitWith logs a critical message and terminates with the given exit code
+func exitWith(code int, args ...interface{}) {
+	log.Critical(args...)
+	log.Infof("Run %s ended with exit code %d", runID, code)
+	if jsonSummary {
+		status := "failed"
+		switch code {
+		case exitInterrupted:
+			status = "interrupted"
+		case exitTimeout:
+			status = "timeout"
+		}
+		var runErr error
+		if len(args) == 1 {
+			runErr, _ = args[0].(error)
+		}
+		if runErr == nil {
+			runErr = errors.New(fmt.Sprint(args...))
+		}
+		finalSummary().writeJSON(os.Stdout, status, runErr)
+	}
+	saveWarnings()
+	os.Exit(code)
 }
 
 // createFile creates new file
@@ -227,7 +1576,13 @@ func createFile(path string) *os.File {
 		return nil
 	}
 
-	fh, err := os.Create(path)
+	var fh *os.File
+	var err error
+	if isSpecialFile(path) {
+		fh, err = openSpecialFile(path)
+	} else {
+		fh, err = os.Create(path)
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"flag"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/baloo32/xerobanktransform/internal/statementgen"
+	"github.com/op/go-logging"
+)
+
+func TestMain(m *testing.M) {
+	// Options start at their command line defaults, with the log kept quiet
+	defineFlags(flag.NewFlagSet("defaults", flag.ContinueOnError))
+	if !includeIndex {
+		indexOrder = ""
+	}
+	logging.SetLevel(logging.CRITICAL, "")
+	os.Exit(m.Run())
+}
+
+// setForTest changes a setting for the length of a test
+func setForTest[T any](t *testing.T, setting *T, value T) {
+	t.Helper()
+	old := *setting
+	*setting = value
+	t.Cleanup(func() { *setting = old })
+}
+
+// statementHeader starts a statement in the default bank format
+const statementHeader = "Account Name,Test,,,,,\n" +
+	"Transactions,,,,,,\n" +
+	" Date,Description,Bank     Reference,Customer  Reference,Debit,Credit,Running  Balance  \n"
+
+// transformStatement transforms a statement with the default preset, changed by adjust when not nil,
+// returning the CSV output. The summary is started afresh.
+func transformStatement(t *testing.T, statement string, adjust func(*Preset)) string {
+	t.Helper()
+	output, err := transformStatementErr(statement, adjust)
+	if err != nil {
+		t.Fatalf("transform failed: %s", err)
+	}
+	return output
+}
+
+// transformStatementErr transforms a statement like transformStatement, returning any error stopping the run
+func transformStatementErr(statement string, adjust func(*Preset)) (string, error) {
+	startRun()
+	preset := builtinPresets[defaultPresetName]
+	if adjust != nil {
+		adjust(&preset)
+	}
+	var buf bytes.Buffer
+	out, err := newTransactionWriter(formatCSV, &buf)
+	if err != nil {
+		return "", err
+	}
+	if err := out.WriteHeader(); err != nil {
+		return "", err
+	}
+	in := input{name: "test.csv", reader: ioutil.NopCloser(strings.NewReader(statement))}
+	err = Run(context.Background(), []input{in}, &preset, ',', out, ProgressHook{})
+	if flushErr := out.Flush(); err == nil {
+		err = flushErr
+	}
+	return buf.String(), err
+}
+
+// startRun clears what a previous run left behind
+func startRun() {
+	summary = Summary{}
+	progress = Progress{}
+	outputClosed = false
+}
+
+// outputRows splits CSV output into its rows after the header
+func outputRows(output string) []string {
+	rows := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	return rows[1:]
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		quirks statementgen.Quirks
+	}{
+		{"plain", statementgen.Quirks{DebitRatio: 0.5}},
+		{"bom", statementgen.Quirks{BOM: true, DebitRatio: 0.5}},
+		{"odd spacing", statementgen.Quirks{OddSpacing: true, DebitRatio: 0.5}},
+		{"repeated header", statementgen.Quirks{RepeatHeaderEvery: 7, DebitRatio: 0.5}},
+		{"credits only", statementgen.Quirks{DebitRatio: 0}},
+		{"debits only", statementgen.Quirks{DebitRatio: 1}},
+		{"every quirk", statementgen.Quirks{BOM: true, OddSpacing: true, RepeatHeaderEvery: 3, DebitRatio: 0.3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for seed := int64(1); seed <= 5; seed++ {
+				statement := statementgen.Generate(seed, 50, tt.quirks)
+				output := transformStatement(t, string(statement.CSV), nil)
+				if err := statement.Check(strings.NewReader(output)); err != nil {
+					t.Errorf("seed %d: %s", seed, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRepeatedRunsMatch(t *testing.T) {
+	setForTest(t, &coalesceBy, "Description")
+	setForTest(t, &categoryRules, []categoryRule{{"customer 1", "Regular"}, {"payment 2", "Payments"}})
+	statement := string(statementgen.Generate(1, 200, statementgen.Quirks{DebitRatio: 0.5}).CSV)
+
+	// transform writes the output followed by the daily and pivot reports of a fresh run
+	transform := func() string {
+		setForTest(t, &dailyReport, newDailyTotals())
+		setForTest(t, &pivotReport, pivotTotals{})
+		output := transformStatement(t, statement, nil)
+		var reports bytes.Buffer
+		if err := dailyReport.write(&reports); err != nil {
+			t.Fatal(err)
+		}
+		if err := pivotReport.write(&reports); err != nil {
+			t.Fatal(err)
+		}
+		return output + reports.String()
+	}
+	first := transform()
+	for run := 2; run <= 5; run++ {
+		if again := transform(); again != first {
+			t.Fatalf("run %d differs from the first:\n%s\nfirst:\n%s", run, again, first)
+		}
+	}
+	if summary.Written >= summary.Read {
+		t.Errorf("no transactions were coalesced, %d written of %d read", summary.Written, summary.Read)
+	}
+}
+
+func TestCancelMidStream(t *testing.T) {
+	startRun()
+	preset := builtinPresets[defaultPresetName]
+	statement := string(statementgen.Generate(1, 10, statementgen.Quirks{DebitRatio: 0.5}).CSV)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// Interrupt the run as the third transaction is read
+	hook := ProgressHook{Every: 3, Func: func(Progress) { cancel() }}
+
+	var buf bytes.Buffer
+	out, err := newTransactionWriter(formatCSV, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := out.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	in := input{name: "test.csv", reader: ioutil.NopCloser(strings.NewReader(statement))}
+	err = runWithDeadline(ctx, []input{in}, &preset, ',', out, hook)
+	if err != context.Canceled {
+		t.Fatalf("got error %v, want %v", err, context.Canceled)
+	}
+	if err := finishOutput(out); err != nil {
+		t.Fatal(err)
+	}
+
+	// The transactions read before the interruption are all written in full
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("partial output isn't valid CSV: %s", err)
+	}
+	if len(rows)-1 != 3 || finalSummary().Written != 3 {
+		t.Errorf("got %d rows and %d written, want 3", len(rows)-1, finalSummary().Written)
+	}
+}
+
+func FuzzTransform(f *testing.F) {
+	for _, seed := range statementgen.Seeds() {
+		f.Add(seed.CSV)
+	}
+	f.Fuzz(func(t *testing.T, statement []byte) {
+		// Dates are only read, and so checked, when the preset gives their format
+		output, err := transformStatementErr(string(statement), func(p *Preset) { p.DateFormat = "02/01/2006" })
+		if err != nil {
+			return
+		}
+		// Rows written are complete, with amounts Xero can read or left blank, and dates it can read
+		// unless the row was warned about
+		rows, err := csv.NewReader(strings.NewReader(output)).ReadAll()
+		if err != nil {
+			t.Fatalf("output isn't valid CSV: %s\n%s", err, output)
+		}
+		if len(rows) == 0 {
+			t.Fatalf("output has no header row")
+		}
+		unreadDates := 0
+		for i, row := range rows[1:] {
+			if len(row) != len(rows[0]) {
+				t.Fatalf("row %d has %d cells, the header %d: %q", i+1, len(row), len(rows[0]), row)
+			}
+			if _, err := parseDate(row[0], outputDateFormat); err != nil {
+				unreadDates++
+			}
+			if _, err := parseAmountWith(row[1], outputNumberFormat); err != nil && row[1] != "" {
+				t.Fatalf("row %d: %s", i+1, err)
+			}
+		}
+		if unreadDates > len(summary.Warnings) {
+			t.Fatalf("%d rows have dates that can't be read, but there are only %d warnings", unreadDates, len(summary.Warnings))
+		}
+	})
+}
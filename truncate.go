@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ellipsis marks a truncated value when -truncateellipsis is set
+const ellipsis = "..."
+
+// defaultMaxLengths are the longest values Xero accepts for the text fields of a statement line
+var defaultMaxLengths = map[string]int{
+	"Payee":        255,
+	"Description":  255,
+	"Reference":    255,
+	"ChequeNumber": 20,
+}
+
+// parseMaxLengths overlays a "Field=length,..." specification on the default maximum lengths.
+// A length of 0 disables truncation of that field.
+func parseMaxLengths(spec string) (map[string]int, error) {
+	lengths := map[string]int{}
+	for field, length := range defaultMaxLengths {
+		lengths[field] = length
+	}
+	if strings.TrimSpace(spec) == "" {
+		return lengths, nil
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid maximum length %q, expected Field=length", entry)
+		}
+		field := strings.TrimSpace(parts[0])
+		if transformFieldRef(&Transform{}, field) == nil {
+			return nil, fmt.Errorf("unknown field %q in maximum lengths", field)
+		}
+		length, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || length < 0 {
+			return nil, fmt.Errorf("invalid maximum length %q for %s", parts[1], field)
+		}
+		lengths[canonicalFieldName(field)] = length
+	}
+
+	return lengths, nil
+}
+
+// transformFieldNames lists the Transform fields in output order
+var transformFieldNames = []string{"Date", "Amount", "Payee", "Description", "Reference", "ChequeNumber", "TransactionType"}
+
+// canonicalFieldName returns the properly cased Transform field name for a field reference
+func canonicalFieldName(name string) string {
+	t := &Transform{}
+	for _, field := range transformFieldNames {
+		if transformFieldRef(t, field) == transformFieldRef(t, name) {
+			return field
+		}
+	}
+	return name
+}
+
+// truncate shortens a value to at most max characters, ending it with an ellipsis if wanted
+func truncate(value string, max int, withEllipsis bool) string {
+	if max <= 0 || utf8.RuneCountInString(value) <= max {
+		return value
+	}
+	runes := []rune(value)
+	if withEllipsis && max > len(ellipsis) {
+		return string(runes[:max-len(ellipsis)]) + ellipsis
+	}
+	return string(runes[:max])
+}
+
+// truncateTransform shortens any over-long fields of a transaction
+func truncateTransform(t *Transform, lengths map[string]int, withEllipsis bool, line int) {
+	for _, field := range transformFieldNames {
+		max, ok := lengths[field]
+		if !ok {
+			continue
+		}
+		value := transformFieldRef(t, field)
+		truncated := truncate(*value, max, withEllipsis)
+		if truncated != *value {
+			warnRow(line, "Truncated %s on line %d to %d characters: %q", field, line, max, *value)
+			*value = truncated
+		}
+	}
+}
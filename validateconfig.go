@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// configProblem is something wrong with a config file. Errors make the config unusable,
+// while warnings point at settings that are likely mistakes.
+type configProblem struct {
+	fatal   bool
+	message string
+}
+
+// validateConfig checks a config file, cross-checking its columns against the headers of
+// the import file when one is given. It reports whether the config is usable.
+func validateConfig(path string, importPath string) bool {
+	problems := checkConfigFile(path, importPath)
+	usable := true
+	for _, p := range problems {
+		if p.fatal {
+			usable = false
+			log.Errorf("ERROR %s", p.message)
+			continue
+		}
+		log.Warningf("WARNING %s", p.message)
+	}
+	if len(problems) == 0 {
+		log.Noticef("No problems found in %s", path)
+	}
+	return usable
+}
+
+// checkConfigFile lists the problems with a config file
+func checkConfigFile(path string, importPath string) []configProblem {
+	content, err := readConfigFile(path)
+	if err != nil {
+		return []configProblem{{fatal: true, message: fmt.Sprintf("invalid config %s: %s", path, err)}}
+	}
+	var preset Preset
+	// Misspelt settings would otherwise be silently ignored
+	if err := decodeConfig(content, &preset); err != nil {
+		return []configProblem{{fatal: true, message: fmt.Sprintf("invalid config %s: %s", path, err)}}
+	}
+
+	var problems []configProblem
+	problem := func(fatal bool, format string, args ...interface{}) {
+		problems = append(problems, configProblem{fatal: fatal, message: fmt.Sprintf(format, args...)})
+	}
+
+	delimiter, err := delimiterRune(preset.Delimiter)
+	if err != nil {
+		problem(true, "%s", err)
+	}
+	if len(preset.HeaderSignature) == 0 {
+		problem(true, "no headerSignature to find the header row with")
+	}
+	if preset.SkipToMarker && len(preset.SectionMarkers) == 0 {
+		problem(true, "skipToMarker is set without any sectionMarkers")
+	}
+	columns := preset.Columns
+	if columns.Date == "" {
+		problem(true, "no date column")
+	}
+	if columns.Amount == "" && columns.Debit == "" && columns.Credit == "" {
+		problem(true, "no amount, debit or credit column")
+	}
+	if columns.Indicator != "" && columns.Amount == "" {
+		problem(true, "the indicator column %q needs an amount column", columns.Indicator)
+	}
+	if columns.Direction != "" && columns.Amount == "" {
+		problem(true, "the direction column %q needs an amount column", columns.Direction)
+	}
+	if columns.Indicator != "" && columns.Direction != "" {
+		problem(false, "the direction column %q is ignored with an indicator column", columns.Direction)
+	}
+	if err := validateAmountStrategies(&preset); err != nil {
+		problem(true, "%s", err)
+	}
+	if columns.Indicator == "" && (len(preset.DebitIndicators) > 0 || len(preset.CreditIndicators) > 0) {
+		problem(false, "debitIndicators and creditIndicators are ignored without an indicator column")
+	}
+	if preset.DateFormat != "" && !isTimestampFormat(preset.DateFormat) && time.Date(2001, 2, 3, 0, 0, 0, 0, time.UTC).Format(preset.DateFormat) == preset.DateFormat {
+		problem(true, "dateFormat %q has no Go time layout elements such as 02 or 2006", preset.DateFormat)
+	}
+	if len(columns.Description) == 0 && len(columns.Reference) == 0 && len(columns.Payee) == 0 {
+		problem(false, "no payee, description or reference columns, so transactions will have no text")
+	}
+
+	if importPath == "" || err != nil {
+		return problems
+	}
+	inputs, err := openInputs(importPath)
+	if err != nil {
+		problem(true, "%s", err)
+		return problems
+	}
+	for _, in := range inputs {
+		headers, err := readHeader(newStatementReader(in.reader, delimiter), in.name, &preset)
+		in.reader.Close()
+		if err != nil {
+			problem(true, "%s", err)
+			continue
+		}
+		for _, column := range mappedColumns(columns) {
+			if !containsString(headers, column) {
+				problem(true, "column %q isn't in %s, which has %q", column, in.name, headers)
+			}
+		}
+	}
+	return problems
+}
+
+// mappedColumns lists the source columns a mapping uses
+func mappedColumns(columns ColumnMapping) []string {
+	var used []string
+	for _, column := range []string{columns.Date, columns.Debit, columns.Credit, columns.Amount, columns.Indicator, columns.Currency, columns.Balance, columns.Direction} {
+		if column != "" {
+			used = append(used, column)
+		}
+	}
+	for _, list := range [][]string{columns.Payee, columns.Description, columns.Reference, columns.ChequeNumber} {
+		for _, column := range list {
+			for _, alternative := range strings.Split(column, columnAlternatives) {
+				used = append(used, strings.TrimSpace(alternative))
+			}
+		}
+	}
+	return used
+}
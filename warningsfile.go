@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Formats of the warnings file
+const (
+	// GitHub Actions workflow commands, which annotate the files and lines they name
+	warningsGitHub = "github"
+	// A JSON object per line
+	warningsJSON = "json"
+)
+
+// validateWarningsFormat checks the -warningsformat value
+func validateWarningsFormat(format string) error {
+	switch format {
+	case warningsGitHub, warningsJSON:
+		return nil
+	}
+	return fmt.Errorf("unknown warnings format %q, expected %s or %s", format, warningsGitHub, warningsJSON)
+}
+
+// warningRecord is a line of the JSON warnings file
+type warningRecord struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// writeWarnings writes the rejected rows as errors, then the warnings about rows still written,
+// a line each in the given format
+func writeWarnings(w io.Writer, format string, s *Summary) error {
+	enc := json.NewEncoder(w)
+	for _, list := range []struct {
+		severity string
+		issues   []Issue
+	}{{"error", s.Issues}, {"warning", s.Warnings}} {
+		for _, issue := range list.issues {
+			var err error
+			if format == warningsJSON {
+				err = enc.Encode(warningRecord{File: issue.File, Line: issue.Line, Severity: list.severity, Message: issue.Reason})
+			} else {
+				_, err = fmt.Fprintf(w, "::%s file=%s,line=%d::%s\n", list.severity,
+					escapeWorkflowProperty(issue.File), issue.Line, escapeWorkflowData(issue.Reason))
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// escapeWorkflowData escapes the message of a workflow command
+func escapeWorkflowData(value string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(value)
+}
+
+// escapeWorkflowProperty escapes a property value of a workflow command
+func escapeWorkflowProperty(value string) string {
+	return strings.NewReplacer(":", "%3A", ",", "%2C").Replace(escapeWorkflowData(value))
+}
+
+// File the rejected rows and warnings are written into, and its format
+var (
+	warningsPath   string
+	warningsFormat string
+)
+
+// saveWarnings writes the warnings file, if one is wanted, whether or not the run succeeded
+func saveWarnings() {
+	if warningsPath == "" {
+		return
+	}
+	s := finalSummary()
+	f, err := os.Create(warningsPath)
+	if err == nil {
+		err = writeWarnings(f, warningsFormat, s)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		log.Errorf("Unable to write the warnings to %s: %s", warningsPath, err)
+		return
+	}
+	log.Noticef("%d rejected rows and %d warnings written to %s", len(s.Issues), len(s.Warnings), warningsPath)
+}
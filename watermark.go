@@ -0,0 +1,48 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+// Layout of the date kept in a watermark file
+const watermarkLayout = "2006-01-02"
+
+// Latest transaction date written by the previous run, zero when there was none
+var watermark time.Time
+
+// readWatermark reads the date kept in a watermark file, which is zero on the first run when
+// there is no file yet
+func readWatermark(path string) (time.Time, error) {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(watermarkLayout, strings.TrimSpace(string(content)))
+}
+
+// writeWatermark replaces the date kept in a watermark file
+func writeWatermark(path string, date time.Time) error {
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(date.Format(watermarkLayout)+"\n"), 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// belowWatermark reports whether a transaction was already processed by an earlier run
+func belowWatermark(t *transaction) bool {
+	if watermark.IsZero() {
+		return false
+	}
+	if t.date.IsZero() {
+		warnRow(t.lines[0], "Unable to compare the date %q on line %d with the watermark, keeping it", t.Date, t.lines[0])
+		return false
+	}
+	return !t.date.After(watermark)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+)
+
+// Policies for transactions with a zero amount, such as fee reversals and informational rows
+const (
+	// Write them like any other transaction
+	zeroKeep = "keep"
+	// Skip them
+	zeroDrop = "drop"
+	// Reject them as problem rows
+	zeroReject = "reject"
+)
+
+// What happens to transactions with a zero amount
+var zeroPolicy string
+
+// What happens to transactions with no amount at all, told apart from a zero amount: a missing
+// amount is written blank when kept, while an amount read as zero is always written as 0.00
+var missingAmountPolicy string
+
+// validateZeroPolicy checks the -zeropolicy or -missingamountpolicy value
+func validateZeroPolicy(policy string) error {
+	switch policy {
+	case zeroKeep, zeroDrop, zeroReject:
+		return nil
+	}
+	return fmt.Errorf("unknown amount policy %q, expected %s, %s or %s", policy, zeroKeep, zeroDrop, zeroReject)
+}
+
+// keepZeroAmount counts a transaction with a zero amount and applies the -zeropolicy to it,
+// returning false when it is not to be written
+func keepZeroAmount(t *transaction, row []string, line int) (bool, error) {
+	if !t.hasAmount || t.amount != 0 {
+		return true, nil
+	}
+	summary.ZeroAmounts++
+	switch zeroPolicy {
+	case zeroDrop:
+		log.Debugf("Dropping line %d as its amount is zero", line)
+		explainf(line, "Dropped as its amount is zero, see -zeropolicy")
+		summary.Skipped++
+		return false, nil
+	case zeroReject:
+		return false, rejectRow(line, row, "amount is zero")
+	}
+	return true, nil
+}
+
+// keepMissingAmount counts a transaction without an amount and applies the -missingamountpolicy to it,
+// returning false when it is not to be written
+func keepMissingAmount(t *transaction, row []string, line int) (bool, error) {
+	if t.hasAmount {
+		return true, nil
+	}
+	summary.MissingAmounts++
+	switch missingAmountPolicy {
+	case zeroDrop:
+		log.Debugf("Dropping line %d as it has no amount", line)
+		explainf(line, "Dropped as it has no amount, see -missingamountpolicy")
+		summary.Skipped++
+		return false, nil
+	case zeroReject:
+		return false, rejectRow(line, row, "no amount")
+	}
+	return true, nil
+}
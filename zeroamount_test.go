@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMissingAndZeroAmounts(t *testing.T) {
+	statement := statementHeader +
+		"01/06/2020,CARD,REF1,TESCO,4.01,,1\n" +
+		"02/06/2020,FEE,REF2,REVERSAL,0.00,,1\n" +
+		"03/06/2020,NOTE,REF3,INFO,,,1\n"
+	debit := "01/06/2020,-4.01,,TESCO,CARD REF1,,Debit"
+	zero := "02/06/2020,0.00,,REVERSAL,FEE REF2,,Debit"
+	missing := "03/06/2020,,,INFO,NOTE REF3,,"
+	tests := []struct {
+		name          string
+		zeroPolicy    string
+		missingPolicy string
+		want          []string
+		wantSkipped   int
+		wantErr       error
+	}{
+		{"both kept", zeroKeep, zeroKeep, []string{debit, zero, missing}, 0, nil},
+		{"missing dropped", zeroKeep, zeroDrop, []string{debit, zero}, 1, nil},
+		{"zero dropped", zeroDrop, zeroKeep, []string{debit, missing}, 1, nil},
+		{"both dropped", zeroDrop, zeroDrop, []string{debit}, 2, nil},
+		{"missing rejected", zeroKeep, zeroReject, nil, 0, ErrBadRow},
+		{"zero rejected", zeroReject, zeroKeep, nil, 0, ErrBadRow},
+	}
+	for _, tt := range tests {
+		setForTest(t, &zeroPolicy, tt.zeroPolicy)
+		setForTest(t, &missingAmountPolicy, tt.missingPolicy)
+		output, err := transformStatementErr(statement, nil)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: got error %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got := outputRows(output); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got rows %q, want %q", tt.name, got, tt.want)
+		}
+		if summary.Skipped != tt.wantSkipped || summary.ZeroAmounts != 1 || summary.MissingAmounts != 1 {
+			t.Errorf("%s: got %d skipped, %d zero and %d missing amounts, want %d, 1 and 1",
+				tt.name, summary.Skipped, summary.ZeroAmounts, summary.MissingAmounts, tt.wantSkipped)
+		}
+	}
+}
+
+func TestMissingAmountRejectedCollected(t *testing.T) {
+	setForTest(t, &errorStrategy, strategyCollect)
+	setForTest(t, &missingAmountPolicy, zeroReject)
+	statement := statementHeader +
+		"01/06/2020,FEE,REF2,REVERSAL,0.00,,1\n" +
+		"02/06/2020,NOTE,REF3,INFO,  ,,1\n"
+	got := outputRows(transformStatement(t, statement, nil))
+	// A zero amount is still written, as 0.00, while the missing one is rejected with the reason
+	if want := []string{"01/06/2020,0.00,,REVERSAL,FEE REF2,,Debit"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got rows %q, want %q", got, want)
+	}
+	if summary.Rejected != 1 || len(summary.Issues) != 1 || summary.Issues[0].Reason != "no amount" {
+		t.Errorf("got %d rejected with issues %+v, want 1 for no amount", summary.Rejected, summary.Issues)
+	}
+}